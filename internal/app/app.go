@@ -1,11 +1,20 @@
 package app
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/blocklist"
+	"github.com/leodayo/url-shortener/internal/app/cidr"
 	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/filewatch"
 	"github.com/leodayo/url-shortener/internal/app/handlers"
+	"github.com/leodayo/url-shortener/internal/app/middleware"
+	"github.com/leodayo/url-shortener/internal/app/oidc"
 	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/compression/gzip"
+	"github.com/leodayo/url-shortener/internal/compression/zstd"
 	"github.com/leodayo/url-shortener/internal/logger"
 )
 
@@ -20,9 +29,91 @@ func Run() error {
 		return err
 	}
 
+	if err := gzip.SetLevel(config.GzipLevel); err != nil {
+		return err
+	}
+	zstd.SetMaxMemory(config.MaxDecompressedSize)
+
+	if err := auth.Init(); err != nil {
+		return err
+	}
+
+	if err := initOIDC(); err != nil {
+		return err
+	}
+
 	if err := storage.InitFileStorage(); err != nil {
 		return err
 	}
 
+	if err := watchAccessLists(); err != nil {
+		return err
+	}
+
+	if err := watchDomainBlocklist(); err != nil {
+		return err
+	}
+
 	return http.ListenAndServe(config.ServerAddress, handlers.MainRouter())
 }
+
+func initOIDC() error {
+	if config.OIDCIssuer == "" {
+		return nil
+	}
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       config.OIDCIssuer,
+		ClientID:     config.OIDCClientID,
+		ClientSecret: config.OIDCClientSecret,
+		RedirectURL:  config.OIDCRedirectURL,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	handlers.OIDC = provider
+	return nil
+}
+
+func watchAccessLists() error {
+	lists := []struct {
+		path string
+		set  *cidr.Set
+	}{
+		{path: config.TrustedSubnetFile, set: &middleware.TrustedNetworks},
+		{path: config.DenylistFile, set: &middleware.DeniedNetworks},
+	}
+
+	for _, list := range lists {
+		if list.path == "" {
+			continue
+		}
+
+		set := list.set
+		_, err := filewatch.Watch(list.path, config.AccessListsInterval, func(data []byte) error {
+			list, err := cidr.ParseFile(data)
+			if err != nil {
+				return err
+			}
+			set.Store(list)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func watchDomainBlocklist() error {
+	if config.DomainBlocklistFile == "" {
+		return nil
+	}
+
+	_, err := filewatch.Watch(config.DomainBlocklistFile, config.AccessListsInterval, func(data []byte) error {
+		handlers.BlockedDomains.Store(blocklist.Parse(data))
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/leodayo/url-shortener/internal/app/randstr"
+)
+
+const (
+	apiKeyPrefix = "usk_"
+	apiKeyLength = 40
+)
+
+// NewAPIKey generates an API key, to be shown to its owner once, and the
+// hash to store in its place.
+func NewAPIKey() (key, hash string, err error) {
+	secret, err := randstr.RandString(apiKeyLength)
+	if err != nil {
+		return "", "", err
+	}
+
+	key = apiKeyPrefix + secret
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey hashes a key for storage and lookup. Keys are long random
+// strings, so a fast unsalted hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/jwt"
+	"github.com/leodayo/url-shortener/internal/app/randstr"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/logger"
+)
+
+const (
+	CookieName   = "auth"
+	cookieMaxAge = 365 * 24 * time.Hour
+	userIDLength = 16
+	// csrfPrefix keeps CSRF tokens from being valid signatures of user IDs.
+	csrfPrefix = "csrf\x00"
+)
+
+// secret signs auth cookies. It is random until Init loads the configured
+// one.
+var secret = make([]byte, 32)
+
+func init() {
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+}
+
+// Tokens verifies JWTs sent as Bearer tokens and signs new ones. It is
+// nil unless JWT keys are configured.
+var Tokens *jwt.KeySet
+
+// Init loads the configured cookie secret and JWT keys. Without a secret
+// the random one is kept, so cookies don't survive a restart.
+func Init() error {
+	if config.AuthSecret == "" {
+		logger.Log.Warn("no auth secret configured, auth cookies will be invalidated on restart")
+	} else {
+		secret = []byte(config.AuthSecret)
+	}
+
+	if config.JWTKeysDir != "" {
+		keySet, err := jwt.LoadDir(config.JWTKeysDir)
+		if err != nil {
+			return err
+		}
+		Tokens = keySet
+	}
+	return nil
+}
+
+// ErrorWriter writes an error response, see handlers.JSONError.
+type ErrorWriter func(w http.ResponseWriter, error string, code int)
+
+var errInvalidAPIKey = errors.New("invalid API key")
+
+type identity struct {
+	userID string
+	scope  entity.Scope
+	// jwt is set when the user sent a JWT rather than a cookie or an API
+	// key.
+	jwt bool
+}
+
+type identityKey struct{}
+
+// Middleware identifies the user. Machine clients send a JWT or an API key
+// as a Bearer token and act with its scope. An invalid or expired token is
+// answered with 401 through onError.
+//
+// Other clients are identified by a signed cookie and have full access. A
+// new identity is issued when the cookie is missing or invalid.
+func Middleware(onError ErrorWriter) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var id identity
+
+			if token, ok := bearerToken(r); ok {
+				var err error
+				if id, err = tokenIdentity(token); err != nil {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					onError(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+			} else {
+				userID, ok := userFromCookie(r)
+				if !ok {
+					var err error
+					userID, err = randstr.RandString(userIDLength)
+					if err != nil {
+						onError(w, "Something went wrong", http.StatusInternalServerError)
+						return
+					}
+					http.SetCookie(w, newCookie(userID))
+				}
+				id = identity{userID: userID, scope: entity.ScopeAdmin}
+			}
+
+			ctx := context.WithValue(r.Context(), identityKey{}, id)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope answers with 403 through onError unless the request is
+// allowed the scope.
+func RequireScope(scope entity.Scope, onError ErrorWriter) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Scope(r.Context()).Includes(scope) {
+				onError(w, fmt.Sprintf("%s scope required", scope), http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UserID returns the ID of the user making the request, or an empty
+// string outside of Middleware.
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(identityKey{}).(identity)
+	return id.userID
+}
+
+// Scope returns what the request is allowed to do, or an empty scope
+// outside of Middleware.
+func Scope(ctx context.Context) entity.Scope {
+	id, _ := ctx.Value(identityKey{}).(identity)
+	return id.scope
+}
+
+// ViaJWT reports whether the request was authenticated with a JWT.
+func ViaJWT(ctx context.Context) bool {
+	id, _ := ctx.Value(identityKey{}).(identity)
+	return id.jwt
+}
+
+// tokenIdentity identifies the sender of a Bearer token, which is a JWT if
+// it has three dot separated parts and an API key otherwise.
+func tokenIdentity(token string) (identity, error) {
+	if strings.Count(token, ".") == 2 {
+		if Tokens == nil {
+			return identity{}, jwt.ErrInvalidToken
+		}
+		claims, err := Tokens.Verify(token, time.Now())
+		if err != nil {
+			return identity{}, err
+		}
+		if scope := entity.Scope(claims.Scope); scope.Valid() {
+			return identity{userID: claims.Subject, scope: scope, jwt: true}, nil
+		}
+		return identity{}, jwt.ErrInvalidToken
+	}
+
+	apiKey, ok := storage.APIKeys.RetrieveAPIKeyByHash(HashAPIKey(token))
+	if !ok {
+		return identity{}, errInvalidAPIKey
+	}
+	return identity{userID: apiKey.UserID, scope: apiKey.Scope}, nil
+}
+
+// IssueToken returns a JWT identifying the user with the scope, valid for
+// config.JWTLifetime.
+func IssueToken(userID string, scope entity.Scope) (token string, expiresAt time.Time, err error) {
+	if Tokens == nil {
+		return "", expiresAt, jwt.ErrNoSigningKey
+	}
+
+	now := time.Now()
+	expiresAt = now.Add(config.JWTLifetime)
+	token, err = Tokens.Sign(jwt.Claims{
+		Subject:   userID,
+		Scope:     string(scope),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	return token, expiresAt, err
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func userFromCookie(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return "", false
+	}
+
+	userID, ok := VerifyValue(cookie.Value)
+	if !ok || userID == "" {
+		return "", false
+	}
+	return userID, true
+}
+
+func newCookie(userID string) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    SignValue(userID),
+		Path:     "/",
+		MaxAge:   int(cookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// StartSession sets the auth cookie of a signed in user. Unlike anonymous
+// identities it lasts for the browser session only.
+func StartSession(w http.ResponseWriter, userID string) {
+	cookie := newCookie(userID)
+	cookie.MaxAge = 0
+	http.SetCookie(w, cookie)
+}
+
+// EndSession removes the auth cookie, the next request gets a new
+// anonymous identity.
+func EndSession(w http.ResponseWriter) {
+	cookie := newCookie("")
+	cookie.Value = ""
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+// SecureCookies reports whether cookies are restricted to HTTPS, which is
+// the case when the service is served over it.
+func SecureCookies() bool {
+	return config.ExpandPath.Scheme == "https"
+}
+
+// SignValue appends a signature to value, for values the client must not
+// change.
+func SignValue(value string) string {
+	return value + "." + hex.EncodeToString(sign(value))
+}
+
+// VerifyValue returns the value signed by SignValue if the signature is
+// valid.
+func VerifyValue(signed string) (string, bool) {
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return "", false
+	}
+
+	value, signature := signed[:i], signed[i+1:]
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(sign(value), actual) {
+		return "", false
+	}
+	return value, true
+}
+
+// CSRFToken returns the token forms of the user must send back. It is
+// bound to the user, so a token seen by one user is useless against
+// another.
+func CSRFToken(userID string) string {
+	return hex.EncodeToString(sign(csrfPrefix + userID))
+}
+
+// ValidCSRFToken reports whether token was issued to the user by CSRFToken.
+func ValidCSRFToken(userID, token string) bool {
+	actual, err := hex.DecodeString(token)
+	return err == nil && hmac.Equal(sign(csrfPrefix+userID), actual)
+}
+
+func sign(value string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
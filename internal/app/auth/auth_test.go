@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignValue(t *testing.T) {
+	signed := SignValue("user")
+	changedSignature := signed[:len(signed)-1] + "0"
+	if changedSignature == signed {
+		changedSignature = signed[:len(signed)-1] + "1"
+	}
+	value, ok := VerifyValue(signed)
+	require.True(t, ok)
+	assert.Equal(t, "user", value)
+
+	tests := []struct {
+		name   string
+		signed string
+	}{
+		{name: "Changed value", signed: "admin" + signed[len("user"):]},
+		{name: "Changed signature", signed: changedSignature},
+		{name: "Not hex", signed: "user.signature"},
+		{name: "No signature", signed: "user"},
+		{name: "Empty", signed: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := VerifyValue(tt.signed)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	token := CSRFToken("user")
+	assert.True(t, ValidCSRFToken("user", token))
+	assert.False(t, ValidCSRFToken("other", token), "a token is bound to its user")
+	assert.False(t, ValidCSRFToken("user", ""))
+	assert.False(t, ValidCSRFToken("user", SignValue("user")), "a signed cookie is not a token")
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := Middleware(func(w http.ResponseWriter, error string, code int) {
+		http.Error(w, error, code)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(UserID(r.Context())))
+	}))
+	serve := func(setup func(r *http.Request)) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		setup(request)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	first := serve(func(*http.Request) {})
+	require.Equal(t, http.StatusOK, first.Code)
+	userID := first.Body.String()
+	assert.NotEmpty(t, userID)
+	cookies := first.Result().Cookies()
+	require.Len(t, cookies, 1, "a new identity should be issued")
+	assert.Equal(t, CookieName, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	t.Run("Cookie keeps identity", func(t *testing.T) {
+		response := serve(func(r *http.Request) { r.AddCookie(cookies[0]) })
+		assert.Equal(t, userID, response.Body.String())
+		assert.Empty(t, response.Result().Cookies())
+	})
+
+	t.Run("Forged cookie gets new identity", func(t *testing.T) {
+		response := serve(func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: CookieName, Value: "admin." + cookies[0].Value[len(userID)+1:]})
+		})
+		assert.NotEqual(t, "admin", response.Body.String())
+		assert.NotEqual(t, userID, response.Body.String())
+		assert.Len(t, response.Result().Cookies(), 1)
+	})
+
+	t.Run("Invalid bearer token", func(t *testing.T) {
+		response := serve(func(r *http.Request) { r.Header.Set("Authorization", "Bearer a.b.c") })
+		assert.Equal(t, http.StatusUnauthorized, response.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, response.Header().Get("WWW-Authenticate"))
+	})
+}
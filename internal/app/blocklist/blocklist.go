@@ -0,0 +1,94 @@
+package blocklist
+
+import (
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/leodayo/url-shortener/internal/logger"
+	"go.uber.org/zap"
+	"golang.org/x/net/idna"
+)
+
+// List matches host names against blocked domains. A plain entry such as
+// "example.com" blocks the domain and all of its subdomains; an entry with
+// '*' wildcards such as "*.example.com" or "paypal-*.com" must match the
+// whole host name.
+type List struct {
+	domains  map[string]struct{}
+	patterns []string
+}
+
+// Parse reads a blocklist file: one entry per line, with everything after
+// '#' treated as a comment. Invalid entries are logged and skipped, so
+// that one bad line does not keep the rest of the file from loading.
+func Parse(data []byte) *List {
+	list := &List{domains: make(map[string]struct{})}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		entry := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(line)), ".")
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "*") {
+			if _, err := path.Match(entry, ""); err != nil {
+				logger.Log.Warn("skipping invalid blocklist pattern", zap.Int("line", i+1), zap.String("entry", entry), zap.Error(err))
+				continue
+			}
+			list.patterns = append(list.patterns, entry)
+			continue
+		}
+
+		domain, err := idna.Lookup.ToASCII(entry)
+		if err != nil {
+			logger.Log.Warn("skipping invalid blocklist domain", zap.Int("line", i+1), zap.String("entry", entry), zap.Error(err))
+			continue
+		}
+		list.domains[domain] = struct{}{}
+	}
+
+	return list
+}
+
+// Blocked reports whether host, lower case and in punycode, is blocked.
+func (l *List) Blocked(host string) bool {
+	if l == nil {
+		return false
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	for domain := host; ; {
+		if _, ok := l.domains[domain]; ok {
+			return true
+		}
+
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+
+	for _, pattern := range l.patterns {
+		if matched, _ := path.Match(pattern, host); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Set is a List that can be swapped atomically while being read, which
+// lets the blocklist be reloaded without a restart.
+type Set struct {
+	list atomic.Pointer[List]
+}
+
+func (s *Set) Store(list *List) {
+	s.list.Store(list)
+}
+
+func (s *Set) Blocked(host string) bool {
+	return s.list.Load().Blocked(host)
+}
@@ -0,0 +1,55 @@
+package blocklist
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocked(t *testing.T) {
+	list := Parse([]byte(`
+# phishing
+evil.example
+*.phish.test   # subdomains only
+paypal-*.com
+Bücher.Example
+`))
+
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{host: "evil.example", expected: true},
+		{host: "login.evil.example", expected: true},
+		{host: "notevil.example", expected: false},
+		{host: "phish.test", expected: false},
+		{host: "www.phish.test", expected: true},
+		{host: "a.b.phish.test", expected: true},
+		{host: "paypal-secure.com", expected: true},
+		{host: "paypal.com", expected: false},
+		{host: "xn--bcher-kva.example", expected: true},
+		{host: "example.com", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.expected, list.Blocked(tt.host))
+		})
+	}
+}
+
+func TestParseSkipsInvalidEntries(t *testing.T) {
+	list := Parse([]byte(`
+[*.example.com
+bad_host.example
+evil.example
+`))
+
+	assert.True(t, list.Blocked("evil.example"), "entries after invalid lines should load")
+	assert.False(t, list.Blocked("bad_host.example"))
+	assert.Empty(t, list.patterns)
+}
+
+func TestEmptySet(t *testing.T) {
+	var set Set
+	assert.False(t, set.Blocked("evil.example"))
+}
@@ -0,0 +1,104 @@
+package cidr
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// List is a set of network prefixes, e.g. parsed from "10.0.0.0/8,192.168.1.1".
+type List []netip.Prefix
+
+// Parse reads a comma-separated list of CIDR prefixes. Bare addresses are
+// treated as single-host prefixes.
+func Parse(s string) (List, error) {
+	var list List
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		prefix, err := ParsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, prefix)
+	}
+
+	return list, nil
+}
+
+// ParsePrefix parses a single CIDR prefix or a bare IP address.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q: %w", s, err)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP address %q: %w", s, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (l List) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l List) String() string {
+	entries := make([]string, len(l))
+	for i, prefix := range l {
+		entries[i] = prefix.String()
+	}
+	return strings.Join(entries, ",")
+}
+
+// ParseFile reads prefixes from a list file: one or more comma-separated
+// entries per line, with everything after '#' treated as a comment.
+func ParseFile(data []byte) (List, error) {
+	var list List
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		entries, err := Parse(line)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, entries...)
+	}
+
+	return list, nil
+}
+
+// Set is a List that can be swapped atomically while being read, which
+// lets access lists be reloaded without a restart.
+type Set struct {
+	list atomic.Pointer[List]
+}
+
+func (s *Set) Load() List {
+	if list := s.list.Load(); list != nil {
+		return *list
+	}
+	return nil
+}
+
+func (s *Set) Store(list List) {
+	s.list.Store(&list)
+}
+
+func (s *Set) Contains(addr netip.Addr) bool {
+	return s.Load().Contains(addr)
+}
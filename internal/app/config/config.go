@@ -1,15 +1,65 @@
 package config
 
 import (
+	"compress/gzip"
+	"errors"
 	"flag"
+	"fmt"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/cidr"
 )
 
 var (
 	ServerAddress   string
 	ExpandPath      url.URL
 	FileStoragePath string
+	TrustedProxies  cidr.List
+
+	TrustedSubnet       cidr.List
+	TrustedSubnetFile   string
+	DenylistFile        string
+	DomainBlocklistFile string
+	AccessListsInterval time.Duration
+
+	CompressMinSize     int
+	GzipLevel           int
+	MaxDecompressedSize int64
+
+	MaxBodySize  int64
+	MaxURLLength int
+
+	AllowedSchemes      []string
+	AllowPrivateTargets bool
+
+	QueryConflict string
+
+	UnlockMaxAttempts    int
+	UnlockAttemptsWindow time.Duration
+
+	AuthSecret  string
+	JWTKeysDir  string
+	JWTLifetime time.Duration
+
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+)
+
+// Rules for forwarded query parameters that are already present in the
+// original URL.
+const (
+	// QueryConflictLink keeps the value of the original URL.
+	QueryConflictLink = "link"
+	// QueryConflictRequest replaces it with the forwarded value.
+	QueryConflictRequest = "request"
+	// QueryConflictAppend keeps both.
+	QueryConflictAppend = "append"
 )
 
 func init() {
@@ -17,12 +67,46 @@ func init() {
 	defaultExpandPath, _ := url.Parse("http://localhost:8080/expand")
 	ExpandPath = *defaultExpandPath
 	FileStoragePath = "storage.json"
+	AccessListsInterval = 10 * time.Second
+	CompressMinSize = 1024
+	GzipLevel = gzip.DefaultCompression
+	MaxDecompressedSize = 1 << 20
+	MaxBodySize = 64 << 10
+	MaxURLLength = 2048
+	AllowedSchemes = []string{"http", "https"}
+	QueryConflict = QueryConflictLink
+	UnlockMaxAttempts = 5
+	UnlockAttemptsWindow = 15 * time.Minute
+	JWTLifetime = time.Hour
 }
 
 func ParseFlags() {
 	flag.StringVar(&ServerAddress, "a", ServerAddress, "server address")
 	flag.Func("b", "base route to expand shortened URL", parseExpandPathFlag)
 	flag.StringVar(&FileStoragePath, "f", FileStoragePath, "file storage path")
+	flag.Func("trusted-proxies", "comma-separated CIDRs of proxies allowed to set client IP headers", parseTrustedProxiesFlag)
+	flag.Func("t", "comma-separated CIDRs allowed to access admin routes", parseTrustedSubnetFlag)
+	flag.StringVar(&TrustedSubnetFile, "trusted-subnet-file", TrustedSubnetFile, "file with additional CIDRs allowed to access admin routes")
+	flag.StringVar(&DenylistFile, "denylist-file", DenylistFile, "file with CIDRs denied access to all routes")
+	flag.StringVar(&DomainBlocklistFile, "domain-blocklist-file", DomainBlocklistFile, "file with domains that cannot be shortened or redirected to")
+	flag.DurationVar(&AccessListsInterval, "access-lists-interval", AccessListsInterval, "how often access list files are checked for changes")
+	flag.IntVar(&CompressMinSize, "compress-min-size", CompressMinSize, "minimum response size in bytes to apply compression")
+	flag.IntVar(&GzipLevel, "gzip-level", GzipLevel, "gzip compression level, from 1 (best speed) to 9 (best compression), -1 for default")
+	flag.Int64Var(&MaxDecompressedSize, "max-decompressed-size", MaxDecompressedSize, "maximum size in bytes of a decompressed request body")
+	flag.Int64Var(&MaxBodySize, "max-body-size", MaxBodySize, "maximum size in bytes of a shorten request body")
+	flag.IntVar(&MaxURLLength, "max-url-length", MaxURLLength, "maximum length of a URL to shorten")
+	flag.Func("allowed-schemes", "comma-separated URL schemes accepted for shortening (default \"http,https\")", parseAllowedSchemesFlag)
+	flag.BoolVar(&AllowPrivateTargets, "allow-private-targets", AllowPrivateTargets, "allow shortening URLs that point to loopback or private addresses")
+	flag.Func("query-conflict", "how forwarded query parameters already in the original URL are handled: link, request or append (default \"link\")", parseQueryConflictFlag)
+	flag.IntVar(&UnlockMaxAttempts, "unlock-max-attempts", UnlockMaxAttempts, "wrong passwords allowed per protected link within the attempts window")
+	flag.DurationVar(&UnlockAttemptsWindow, "unlock-attempts-window", UnlockAttemptsWindow, "window in which wrong passwords for a protected link are counted")
+	flag.StringVar(&AuthSecret, "auth-secret", AuthSecret, "key used to sign auth cookies, random on every start if empty")
+	flag.StringVar(&JWTKeysDir, "jwt-keys-dir", JWTKeysDir, "directory with the keys used to sign and verify JWTs")
+	flag.DurationVar(&JWTLifetime, "jwt-lifetime", JWTLifetime, "how long issued JWTs are valid")
+	flag.StringVar(&OIDCIssuer, "oidc-issuer", OIDCIssuer, "OpenID Connect issuer users sign in with, sign in is disabled if empty")
+	flag.StringVar(&OIDCClientID, "oidc-client-id", OIDCClientID, "client ID registered with the OpenID Connect issuer")
+	flag.StringVar(&OIDCClientSecret, "oidc-client-secret", OIDCClientSecret, "client secret registered with the OpenID Connect issuer, empty for public clients")
+	flag.StringVar(&OIDCRedirectURL, "oidc-redirect-url", OIDCRedirectURL, "URL of /auth/callback registered with the OpenID Connect issuer")
 
 	flag.Parse()
 }
@@ -44,6 +128,146 @@ func ParseEnv() error {
 		FileStoragePath = fileStoragePath
 	}
 
+	if trustedProxies, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		if err := parseTrustedProxiesFlag(trustedProxies); err != nil {
+			return err
+		}
+	}
+
+	if trustedSubnet, ok := os.LookupEnv("TRUSTED_SUBNET"); ok {
+		if err := parseTrustedSubnetFlag(trustedSubnet); err != nil {
+			return err
+		}
+	}
+
+	if trustedSubnetFile, ok := os.LookupEnv("TRUSTED_SUBNET_FILE"); ok {
+		TrustedSubnetFile = trustedSubnetFile
+	}
+
+	if denylistFile, ok := os.LookupEnv("DENYLIST_FILE"); ok {
+		DenylistFile = denylistFile
+	}
+
+	if domainBlocklistFile, ok := os.LookupEnv("DOMAIN_BLOCKLIST_FILE"); ok {
+		DomainBlocklistFile = domainBlocklistFile
+	}
+
+	if accessListsInterval, ok := os.LookupEnv("ACCESS_LISTS_INTERVAL"); ok {
+		parsedInterval, err := time.ParseDuration(accessListsInterval)
+		if err != nil {
+			return err
+		}
+		AccessListsInterval = parsedInterval
+	}
+
+	if compressMinSize, ok := os.LookupEnv("COMPRESS_MIN_SIZE"); ok {
+		parsedCompressMinSize, err := strconv.Atoi(compressMinSize)
+		if err != nil {
+			return err
+		}
+		CompressMinSize = parsedCompressMinSize
+	}
+
+	if gzipLevel, ok := os.LookupEnv("GZIP_LEVEL"); ok {
+		parsedGzipLevel, err := strconv.Atoi(gzipLevel)
+		if err != nil {
+			return err
+		}
+		GzipLevel = parsedGzipLevel
+	}
+
+	if maxDecompressedSize, ok := os.LookupEnv("MAX_DECOMPRESSED_SIZE"); ok {
+		parsedMaxDecompressedSize, err := strconv.ParseInt(maxDecompressedSize, 10, 64)
+		if err != nil {
+			return err
+		}
+		MaxDecompressedSize = parsedMaxDecompressedSize
+	}
+
+	if maxBodySize, ok := os.LookupEnv("MAX_BODY_SIZE"); ok {
+		parsedMaxBodySize, err := strconv.ParseInt(maxBodySize, 10, 64)
+		if err != nil {
+			return err
+		}
+		MaxBodySize = parsedMaxBodySize
+	}
+
+	if maxURLLength, ok := os.LookupEnv("MAX_URL_LENGTH"); ok {
+		parsedMaxURLLength, err := strconv.Atoi(maxURLLength)
+		if err != nil {
+			return err
+		}
+		MaxURLLength = parsedMaxURLLength
+	}
+
+	if allowedSchemes, ok := os.LookupEnv("ALLOWED_SCHEMES"); ok {
+		if err := parseAllowedSchemesFlag(allowedSchemes); err != nil {
+			return err
+		}
+	}
+
+	if allowPrivateTargets, ok := os.LookupEnv("ALLOW_PRIVATE_TARGETS"); ok {
+		parsedAllowPrivateTargets, err := strconv.ParseBool(allowPrivateTargets)
+		if err != nil {
+			return err
+		}
+		AllowPrivateTargets = parsedAllowPrivateTargets
+	}
+
+	if queryConflict, ok := os.LookupEnv("QUERY_CONFLICT"); ok {
+		if err := parseQueryConflictFlag(queryConflict); err != nil {
+			return err
+		}
+	}
+
+	if unlockMaxAttempts, ok := os.LookupEnv("UNLOCK_MAX_ATTEMPTS"); ok {
+		parsedUnlockMaxAttempts, err := strconv.Atoi(unlockMaxAttempts)
+		if err != nil {
+			return err
+		}
+		UnlockMaxAttempts = parsedUnlockMaxAttempts
+	}
+
+	if unlockAttemptsWindow, ok := os.LookupEnv("UNLOCK_ATTEMPTS_WINDOW"); ok {
+		parsedUnlockAttemptsWindow, err := time.ParseDuration(unlockAttemptsWindow)
+		if err != nil {
+			return err
+		}
+		UnlockAttemptsWindow = parsedUnlockAttemptsWindow
+	}
+
+	if authSecret, ok := os.LookupEnv("AUTH_SECRET"); ok {
+		AuthSecret = authSecret
+	}
+
+	if jwtKeysDir, ok := os.LookupEnv("JWT_KEYS_DIR"); ok {
+		JWTKeysDir = jwtKeysDir
+	}
+
+	if jwtLifetime, ok := os.LookupEnv("JWT_LIFETIME"); ok {
+		parsedJWTLifetime, err := time.ParseDuration(jwtLifetime)
+		if err != nil {
+			return err
+		}
+		JWTLifetime = parsedJWTLifetime
+	}
+
+	if oidcIssuer, ok := os.LookupEnv("OIDC_ISSUER"); ok {
+		OIDCIssuer = oidcIssuer
+	}
+
+	if oidcClientID, ok := os.LookupEnv("OIDC_CLIENT_ID"); ok {
+		OIDCClientID = oidcClientID
+	}
+
+	if oidcClientSecret, ok := os.LookupEnv("OIDC_CLIENT_SECRET"); ok {
+		OIDCClientSecret = oidcClientSecret
+	}
+
+	if oidcRedirectURL, ok := os.LookupEnv("OIDC_REDIRECT_URL"); ok {
+		OIDCRedirectURL = oidcRedirectURL
+	}
+
 	return nil
 }
 
@@ -57,3 +281,47 @@ func parseExpandPathFlag(expandPath string) error {
 	ExpandPath = *parsedExpandURL
 	return nil
 }
+
+func parseTrustedProxiesFlag(trustedProxies string) error {
+	parsedTrustedProxies, err := cidr.Parse(trustedProxies)
+	if err != nil {
+		return err
+	}
+
+	TrustedProxies = parsedTrustedProxies
+	return nil
+}
+
+func parseTrustedSubnetFlag(trustedSubnet string) error {
+	parsedTrustedSubnet, err := cidr.Parse(trustedSubnet)
+	if err != nil {
+		return err
+	}
+
+	TrustedSubnet = parsedTrustedSubnet
+	return nil
+}
+
+func parseAllowedSchemesFlag(allowedSchemes string) error {
+	var parsedAllowedSchemes []string
+	for _, scheme := range strings.Split(allowedSchemes, ",") {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			parsedAllowedSchemes = append(parsedAllowedSchemes, scheme)
+		}
+	}
+
+	if len(parsedAllowedSchemes) == 0 {
+		return errors.New("at least one URL scheme must be allowed")
+	}
+	AllowedSchemes = parsedAllowedSchemes
+	return nil
+}
+
+func parseQueryConflictFlag(queryConflict string) error {
+	switch queryConflict {
+	case QueryConflictLink, QueryConflictRequest, QueryConflictAppend:
+		QueryConflict = queryConflict
+		return nil
+	}
+	return fmt.Errorf("unknown query conflict rule %q", queryConflict)
+}
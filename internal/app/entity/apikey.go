@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+// APIKey lets machine clients act as the user who created it, limited to
+// its scope. Only a hash of the key is stored.
+type APIKey struct {
+	ID        string
+	UserID    string
+	Name      string
+	Hash      string
+	Scope     Scope
+	CreatedAt time.Time
+}
+
+// Scope limits what a client may do. Each scope includes the ones before
+// it: read, write, admin.
+type Scope string
+
+const (
+	// ScopeRead allows reading links and workspaces.
+	ScopeRead Scope = "read"
+	// ScopeWrite also allows creating, changing and deleting them.
+	ScopeWrite Scope = "write"
+	// ScopeAdmin also allows managing API keys.
+	ScopeAdmin Scope = "admin"
+)
+
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+var scopeLevels = map[Scope]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+func (s Scope) Valid() bool {
+	return scopeLevels[s] > 0
+}
+
+// Includes reports whether s allows everything other allows.
+func (s Scope) Includes(other Scope) bool {
+	return s.Valid() && scopeLevels[s] >= scopeLevels[other]
+}
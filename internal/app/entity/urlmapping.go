@@ -1,6 +1,137 @@
 package entity
 
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+)
+
 type ShortenURL struct {
 	ID          string
 	OriginalURL string
+	// OwnerID is the user who created the link. Unless the link belongs
+	// to a workspace, only the owner may access it through the API.
+	OwnerID string
+	// WorkspaceID is the workspace sharing the link, if any. Its members
+	// access the link according to their role.
+	WorkspaceID string
+	// CreatedAt is set by the storage when the link is stored.
+	CreatedAt time.Time
+	Clicks    int64
+	// Title, Notes and Tags are for the owner to organize links, they
+	// don't affect redirects.
+	Title string
+	Notes string
+	Tags  []string
+	// Version is incremented on every update and identifies the state of
+	// the link for optimistic concurrency.
+	Version int64
+	// History lists the last MaxHistory changes of OriginalURL, oldest
+	// first.
+	History []URLChange
+	// DailyClicks counts the clicks of the last ClickHistoryDays days that
+	// had any, oldest first.
+	DailyClicks []DayClicks
+	LinkOptions
+}
+
+// DayClicks is the number of clicks on a UTC day.
+type DayClicks struct {
+	Day    time.Time
+	Clicks int64
+}
+
+// MaxHistory is how many changes of OriginalURL a link keeps. Every update
+// stores the whole link, so its history must stay small.
+const MaxHistory = 50
+
+// ClickHistoryDays is how many days DailyClicks covers.
+const ClickHistoryDays = 90
+
+// URLChange records an update of the original URL of a link.
+type URLChange struct {
+	OldURL    string
+	NewURL    string
+	ChangedBy string
+	ChangedAt time.Time
+}
+
+// LinkOptions are the per-link settings chosen when a link is created.
+// Links are only deduplicated when their options are equal.
+type LinkOptions struct {
+	// Interstitial links always show a "you are leaving" page first.
+	Interstitial bool
+	// RedirectType is the HTTP status used to redirect, zero means
+	// DefaultRedirectType.
+	RedirectType int
+	// ForwardPath appends path segments following the short ID to the
+	// original URL.
+	ForwardPath bool
+	// ForwardQuery merges the query of the short URL request into the
+	// original URL, see config.QueryConflict.
+	ForwardQuery bool
+	// PasswordHash is the bcrypt hash of the password protecting the link.
+	PasswordHash string
+	// MaxClicks is the number of redirects after which the link expires,
+	// zero means unlimited.
+	MaxClicks int64
+	// ActiveFrom and ActiveUntil bound the window in which the link
+	// redirects, zero values leave the window open on that side.
+	ActiveFrom  time.Time
+	ActiveUntil time.Time
+	// FallbackURL is where the link redirects outside of its active
+	// window. Without one it answers as if it didn't exist.
+	FallbackURL string
+}
+
+var (
+	ErrNotFound          = errors.New("link not found")
+	ErrClickLimitReached = errors.New("link click limit reached")
+	ErrIDTaken           = errors.New("link ID already taken")
+)
+
+// ActiveAt reports whether t falls within the link's active window.
+func (e ShortenURL) ActiveAt(t time.Time) bool {
+	if !e.ActiveFrom.IsZero() && t.Before(e.ActiveFrom) {
+		return false
+	}
+	if !e.ActiveUntil.IsZero() && !t.Before(e.ActiveUntil) {
+		return false
+	}
+	return true
+}
+
+// WithClick returns a copy of DailyClicks counting a click at t and
+// dropping days that fall out of the history. The entity's slice is left
+// untouched, as copies of the entity share it.
+func (e ShortenURL) WithClick(t time.Time) []DayClicks {
+	day := t.UTC().Truncate(24 * time.Hour)
+
+	days := slices.Clone(e.DailyClicks)
+	i, found := slices.BinarySearchFunc(days, day, func(d DayClicks, day time.Time) int { return d.Day.Compare(day) })
+	if found {
+		days[i].Clicks++
+	} else {
+		days = slices.Insert(days, i, DayClicks{Day: day, Clicks: 1})
+	}
+
+	oldest := days[len(days)-1].Day.AddDate(0, 0, 1-ClickHistoryDays)
+	first, _ := slices.BinarySearchFunc(days, oldest, func(d DayClicks, day time.Time) int { return d.Day.Compare(day) })
+	return days[first:]
+}
+
+// ClicksExhausted reports whether the link has used up its clicks.
+func (e ShortenURL) ClicksExhausted() bool {
+	return e.MaxClicks > 0 && e.Clicks >= e.MaxClicks
+}
+
+const DefaultRedirectType = http.StatusTemporaryRedirect
+
+// RedirectTypes are the statuses a link may redirect with.
+var RedirectTypes = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
 }
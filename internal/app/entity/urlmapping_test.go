@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithClick(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	var e ShortenURL
+	e.DailyClicks = e.WithClick(day.Add(10 * time.Hour))
+	e.DailyClicks = e.WithClick(day.Add(20 * time.Hour))
+	assert.Equal(t, []DayClicks{{Day: day, Clicks: 2}}, e.DailyClicks)
+
+	shared := e
+	e.DailyClicks = e.WithClick(day.Add(-time.Hour))
+	assert.Equal(t, []DayClicks{{Day: day.AddDate(0, 0, -1), Clicks: 1}, {Day: day, Clicks: 2}}, e.DailyClicks,
+		"a late click should be counted on its own day")
+	assert.Equal(t, []DayClicks{{Day: day, Clicks: 2}}, shared.DailyClicks, "copies should not see the click")
+
+	later := day.AddDate(0, 0, ClickHistoryDays-1)
+	e.DailyClicks = e.WithClick(later)
+	assert.Equal(t, []DayClicks{{Day: day, Clicks: 2}, {Day: later, Clicks: 1}}, e.DailyClicks,
+		"days older than the history should be dropped")
+}
@@ -0,0 +1,62 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+// Workspace shares its links between its members, each with a role.
+type Workspace struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	Members   []Member
+}
+
+type Member struct {
+	UserID string
+	Role   Role
+}
+
+type Role string
+
+const (
+	// RoleViewer can list and inspect links.
+	RoleViewer Role = "viewer"
+	// RoleEditor can also create, change and delete links.
+	RoleEditor Role = "editor"
+	// RoleOwner can also manage the workspace and its members.
+	RoleOwner Role = "owner"
+)
+
+var (
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrWorkspaceNotEmpty = errors.New("workspace still has links")
+)
+
+func (r Role) Valid() bool {
+	return r == RoleViewer || r == RoleEditor || r == RoleOwner
+}
+
+func (r Role) CanView() bool {
+	return r.Valid()
+}
+
+func (r Role) CanEdit() bool {
+	return r == RoleEditor || r == RoleOwner
+}
+
+func (r Role) CanManage() bool {
+	return r == RoleOwner
+}
+
+// Role returns the role of the user in the workspace, empty for users who
+// are not members.
+func (w Workspace) Role(userID string) Role {
+	for _, member := range w.Members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package filewatch
+
+import (
+	"os"
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Watch passes the contents of the file at path to load, then polls the
+// file every interval and calls load again whenever its modification time
+// or size changes. An error from the initial load is returned; errors from
+// later reloads are logged and the previously loaded state is kept.
+// The returned function stops watching.
+func Watch(path string, interval time.Duration, load func(data []byte) error) (stop func(), err error) {
+	info, err := reload(path, load)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			current, err := os.Stat(path)
+			if err != nil {
+				logger.Log.Warn("cannot stat watched file", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			if current.ModTime().Equal(info.ModTime()) && current.Size() == info.Size() {
+				continue
+			}
+
+			reloaded, err := reload(path, load)
+			if err != nil {
+				logger.Log.Error("cannot reload watched file", zap.String("path", path), zap.Error(err))
+				// Remember the broken version so the error is logged once.
+				info = current
+				continue
+			}
+			info = reloaded
+			logger.Log.Info("reloaded watched file", zap.String("path", path))
+		}
+	}()
+
+	return func() { close(done) }, nil
+}
+
+func reload(path string, load func(data []byte) error) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, load(data)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/randstr"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/logger"
+	"github.com/leodayo/url-shortener/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	apiKeyIDLength      = 8
+	maxAPIKeyNameLength = 128
+)
+
+var (
+	errUnknownScope      = errors.New("scope must be one of read, write or admin")
+	errAPIKeyNameTooLong = fmt.Errorf("name must not exceed %d characters", maxAPIKeyNameLength)
+)
+
+// CreateAPIKey creates an API key acting as the user. The key itself is
+// only part of this response, just its hash is stored.
+func CreateAPIKey(response http.ResponseWriter, request *http.Request) {
+	if request.Header.Get("Content-Type") != "application/json" {
+		JSONError(response, "Content-Type not supported", http.StatusBadRequest)
+		return
+	}
+
+	var apiKeyRequest models.APIKeyRequest
+	if err := decodeJSON(http.MaxBytesReader(response, request.Body, config.MaxBodySize), &apiKeyRequest); err != nil {
+		logger.Log.Debug("cannot decode request JSON body", zap.Error(err))
+		JSONError(response, bodyErrorMessage(err), bodyErrorStatus(err))
+		return
+	}
+
+	scope := entity.Scope(apiKeyRequest.Scope)
+	if !scope.Valid() {
+		JSONError(response, errUnknownScope.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(apiKeyRequest.Name)
+	if utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		JSONError(response, errAPIKeyNameTooLong.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := randstr.RandString(apiKeyIDLength)
+	if err != nil {
+		JSONError(response, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	key, hash, err := auth.NewAPIKey()
+	if err != nil {
+		JSONError(response, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	if !storage.APIKeys.StoreAPIKey(entity.APIKey{
+		ID:     id,
+		UserID: auth.UserID(request.Context()),
+		Name:   name,
+		Hash:   hash,
+		Scope:  scope,
+	}) {
+		JSONError(response, "cannot store API key", http.StatusInternalServerError)
+		return
+	}
+
+	apiKey, _ := storage.APIKeys.RetrieveAPIKeyByHash(hash)
+	apiKeyResponse := newAPIKeyResponse(apiKey)
+	apiKeyResponse.Key = key
+	writeJSON(response, http.StatusCreated, "", apiKeyResponse)
+}
+
+// ListAPIKeys lists the API keys of the user, newest first.
+func ListAPIKeys(response http.ResponseWriter, request *http.Request) {
+	apiKeys := storage.APIKeys.ListAPIKeys(auth.UserID(request.Context()))
+	slices.SortFunc(apiKeys, func(a, b entity.APIKey) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), strings.Compare(a.ID, b.ID))
+	})
+
+	apiKeyResponses := make([]models.APIKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		apiKeyResponses = append(apiKeyResponses, newAPIKeyResponse(apiKey))
+	}
+	writeJSON(response, http.StatusOK, "", apiKeyResponses)
+}
+
+// DeleteAPIKey revokes an API key of the user.
+func DeleteAPIKey(response http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	userID := auth.UserID(request.Context())
+
+	owned := slices.ContainsFunc(storage.APIKeys.ListAPIKeys(userID), func(apiKey entity.APIKey) bool {
+		return apiKey.ID == id
+	})
+	if !owned {
+		JSONError(response, entity.ErrAPIKeyNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := storage.APIKeys.DeleteAPIKey(id); err != nil {
+		JSONError(response, err.Error(), http.StatusNotFound)
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func newAPIKeyResponse(apiKey entity.APIKey) models.APIKeyResponse {
+	return models.APIKeyResponse{
+		ID:        apiKey.ID,
+		Name:      apiKey.Name,
+		Scope:     string(apiKey.Scope),
+		CreatedAt: apiKey.CreatedAt,
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	srv := newTestServer(t)
+	user, _ := newUser(t, srv)
+
+	createKey := func(scope string) models.APIKeyResponse {
+		response, err := user.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(`{"name":"ci","scope":"` + scope + `"}`).
+			Post(srv.URL + "/api/keys")
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusCreated, response.StatusCode(), string(response.Body()))
+
+		var apiKey models.APIKeyResponse
+		require.NoError(t, json.Unmarshal(response.Body(), &apiKey))
+		require.NotEmpty(t, apiKey.Key)
+		return apiKey
+	}
+	readKey := createKey("read")
+	writeKey := createKey("write")
+
+	shorten := func(key string) *resty.Response {
+		response, err := resty.New().R().
+			SetAuthToken(key).
+			SetHeader("Content-Type", "application/json").
+			SetBody(`{"url":"https://example.com/build"}`).
+			Post(srv.URL + "/api/shorten")
+		require.NoError(t, err, "error making HTTP request")
+		return response
+	}
+
+	assert.Equal(t, http.StatusForbidden, shorten(readKey.Key).StatusCode(), "read keys cannot create links")
+	response := shorten(writeKey.Key)
+	assert.Equal(t, http.StatusCreated, response.StatusCode())
+	assert.Empty(t, response.Cookies(), "API key requests get no cookie")
+
+	response, err := resty.New().R().SetAuthToken(readKey.Key).Get(srv.URL + "/api/user/urls")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+	var urlResponses []models.URLResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &urlResponses))
+	assert.Len(t, urlResponses, 1, "keys act as the user who created them")
+
+	response, err = resty.New().R().SetAuthToken(writeKey.Key).Get(srv.URL + "/api/keys")
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusForbidden, response.StatusCode(), "managing keys needs the admin scope")
+
+	response, err = user.R().Get(srv.URL + "/api/keys")
+	require.NoError(t, err, "error making HTTP request")
+	var apiKeys []models.APIKeyResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &apiKeys))
+	require.Len(t, apiKeys, 2)
+	for _, apiKey := range apiKeys {
+		assert.Empty(t, apiKey.Key, "keys are only shown when created")
+	}
+
+	response, err = user.R().Delete(srv.URL + "/api/keys/" + writeKey.ID)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode())
+
+	for name, key := range map[string]string{"Revoked": writeKey.Key, "Unknown": "usk_unknown"} {
+		t.Run(name, func(t *testing.T) {
+			response := shorten(key)
+			assert.Equal(t, http.StatusUnauthorized, response.StatusCode())
+			assert.Contains(t, response.Header().Get("Content-Type"), "application/json")
+		})
+	}
+}
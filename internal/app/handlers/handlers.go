@@ -1,22 +1,56 @@
 package handlers
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/blocklist"
 	"github.com/leodayo/url-shortener/internal/app/config"
 	"github.com/leodayo/url-shortener/internal/app/entity"
 	"github.com/leodayo/url-shortener/internal/app/randstr"
 	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/app/urlnorm"
 	"github.com/leodayo/url-shortener/internal/logger"
 	"github.com/leodayo/url-shortener/internal/models"
 	"go.uber.org/zap"
 )
 
-const linkLength = 6
+const (
+	linkLength   = 6
+	maxTags      = 20
+	maxTagLength = 64
+
+	maxTitleLength = 256
+	maxNotesLength = 4096
+)
+
+// BlockedDomains can neither be shortened nor redirected to.
+var BlockedDomains blocklist.Set
+
+var (
+	errURLTooLong   = errors.New("maximum URL length exceeded")
+	errTrailingData = errors.New("unexpected data after JSON object")
+	errStoreFailed  = errors.New("cannot store shortened URL")
+	errBlocked      = errors.New("URL domain is blocked")
+
+	errUnsupportedRedirect = errors.New("redirect_type must be one of 301, 302, 307 or 308")
+	errNegativeMaxClicks   = errors.New("max_clicks must not be negative")
+	errEmptyActiveWindow   = errors.New("active_until must be after active_from")
+	errTooManyTags         = fmt.Errorf("at most %d tags are allowed", maxTags)
+	errTagTooLong          = fmt.Errorf("tags must not exceed %d characters", maxTagLength)
+	errTitleTooLong        = fmt.Errorf("title must not exceed %d characters", maxTitleLength)
+	errNotesTooLong        = fmt.Errorf("notes must not exceed %d characters", maxNotesLength)
+)
 
 func ShortenURL(response http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodPost {
@@ -29,30 +63,23 @@ func ShortenURL(response http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	shortID, err := randstr.RandString(linkLength)
+	body, err := io.ReadAll(http.MaxBytesReader(response, request.Body, config.MaxBodySize))
 	if err != nil {
-		http.Error(response, err.Error(), http.StatusInternalServerError)
+		http.Error(response, bodyErrorMessage(err), bodyErrorStatus(err))
 		return
 	}
 
-	body, err := io.ReadAll(request.Body)
+	originalURL, err := normalizeURL(string(body))
 	if err != nil {
-		http.Error(response, "Something went wrong", http.StatusInternalServerError)
+		http.Error(response, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	originalURL := string(body)
-	parsedURL, err := url.Parse(originalURL)
-	if err != nil || parsedURL.Host == "" {
-		http.Error(response, "Invalid URL", http.StatusBadRequest)
-		return
-	}
-
-	ok := storage.Repository.Store(entity.ShortenURL{ID: shortID, OriginalURL: originalURL})
-
-	if !ok {
-		// Likely a collision happened
-		// TODO: handle collisions gracefully
+	shortenURL, err := shorten(entity.ShortenURL{
+		OriginalURL: originalURL,
+		OwnerID:     auth.UserID(request.Context()),
+	})
+	if err != nil {
 		http.Error(response, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
@@ -60,8 +87,7 @@ func ShortenURL(response http.ResponseWriter, request *http.Request) {
 	response.Header().Set("Content-Type", "text/plain")
 	response.WriteHeader(http.StatusCreated)
 
-	shortenedURL := fmt.Sprintf("%s/%s", config.ExpandPath.String(), shortID)
-	response.Write([]byte(shortenedURL))
+	response.Write([]byte(shortURL(shortenURL.ID)))
 }
 
 func ShortenURLJSON(response http.ResponseWriter, request *http.Request) {
@@ -76,30 +102,101 @@ func ShortenURLJSON(response http.ResponseWriter, request *http.Request) {
 	}
 
 	var shortenRequest models.ShortenRequest
-	dec := json.NewDecoder(request.Body)
-	if err := dec.Decode(&shortenRequest); err != nil {
+	if err := decodeJSON(http.MaxBytesReader(response, request.Body, config.MaxBodySize), &shortenRequest); err != nil {
 		logger.Log.Debug("cannot decode request JSON body", zap.Error(err))
-		JSONError(response, err.Error(), http.StatusInternalServerError)
+		JSONError(response, bodyErrorMessage(err), bodyErrorStatus(err))
 		return
 	}
 
-	originalURL := shortenRequest.URL
-	parsedURL, err := url.Parse(originalURL)
-	if err != nil || parsedURL.Host == "" {
-		JSONError(response, "Invalid URL", http.StatusBadRequest)
+	originalURL, err := normalizeURL(shortenRequest.URL)
+	if err != nil {
+		JSONError(response, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	shortID, err := randstr.RandString(linkLength)
+	if shortenRequest.UTM != nil {
+		originalURL, err = applyUTM(originalURL, shortenRequest.UTM)
+		if err == nil && len(originalURL) > config.MaxURLLength {
+			err = errURLTooLong
+		}
+		if err != nil {
+			JSONError(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var fallbackURL string
+	if shortenRequest.FallbackURL != "" {
+		fallbackURL, err = normalizeURL(shortenRequest.FallbackURL)
+		if err != nil {
+			JSONError(response, "fallback_url: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tags, err := normalizeTags(shortenRequest.Tags)
 	if err != nil {
-		JSONError(response, err.Error(), http.StatusInternalServerError)
+		JSONError(response, err.Error(), http.StatusBadRequest)
 		return
 	}
-	ok := storage.Repository.Store(entity.ShortenURL{ID: shortID, OriginalURL: originalURL})
 
-	if !ok {
-		// Likely a collision happened
-		// TODO: handle collisions gracefully
+	title := strings.TrimSpace(shortenRequest.Title)
+	if err := validateDescription(title, shortenRequest.Notes); err != nil {
+		JSONError(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	linkOptions := entity.LinkOptions{
+		Interstitial: shortenRequest.Interstitial,
+		RedirectType: shortenRequest.RedirectType,
+		ForwardPath:  shortenRequest.ForwardPath,
+		ForwardQuery: shortenRequest.ForwardQuery,
+		MaxClicks:    shortenRequest.MaxClicks,
+		ActiveFrom:   utcOrZero(shortenRequest.ActiveFrom),
+		ActiveUntil:  utcOrZero(shortenRequest.ActiveUntil),
+		FallbackURL:  fallbackURL,
+	}
+	if err := validateLinkOptions(linkOptions); err != nil {
+		JSONError(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := auth.UserID(request.Context())
+	if shortenRequest.WorkspaceID != "" {
+		workspace, ok := storage.Workspaces.RetrieveWorkspace(shortenRequest.WorkspaceID)
+		if !ok {
+			JSONError(response, entity.ErrWorkspaceNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		if err := checkWorkspaceAccess(workspace, userID, entity.Role.CanEdit); err != nil {
+			JSONError(response, err.Error(), workspaceErrorStatus(err))
+			return
+		}
+	}
+
+	if shortenRequest.Password != "" {
+		linkOptions.PasswordHash, err = hashPassword(shortenRequest.Password)
+		if err != nil {
+			JSONError(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	shortenURL, err := shorten(entity.ShortenURL{
+		OriginalURL: originalURL,
+		OwnerID:     userID,
+		WorkspaceID: shortenRequest.WorkspaceID,
+		Title:       title,
+		Notes:       shortenRequest.Notes,
+		Tags:        tags,
+		LinkOptions: linkOptions,
+	})
+	if errors.Is(err, entity.ErrWorkspaceNotFound) {
+		// Deleted since its access was checked.
+		JSONError(response, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		JSONError(response, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
@@ -108,9 +205,9 @@ func ShortenURLJSON(response http.ResponseWriter, request *http.Request) {
 	response.Header().Set("X-Content-Type-Options", "nosniff")
 	response.WriteHeader(http.StatusCreated)
 
-	shortenedURL := fmt.Sprintf("%s/%s", config.ExpandPath.String(), shortID)
 	shortenResponse := models.ShortenResponse{
-		Result: shortenedURL,
+		Result:      shortURL(shortenURL.ID),
+		Destination: shortenURL.OriginalURL,
 	}
 
 	enc := json.NewEncoder(response)
@@ -121,20 +218,224 @@ func ShortenURLJSON(response http.ResponseWriter, request *http.Request) {
 	}
 }
 
-func GetOriginalURL(response http.ResponseWriter, request *http.Request) {
-	if request.Method != http.MethodGet {
-		http.Error(response, "Not supported", http.StatusMethodNotAllowed)
-		return
+func GetStats(response http.ResponseWriter, request *http.Request) {
+	stats := models.StatsResponse{
+		URLs: storage.Repository.Count(),
 	}
 
-	requestedID := request.PathValue("id")
-	shortenURL, ok := storage.Repository.Retrieve(requestedID)
-	if !ok {
-		http.Error(response, "Link not found", http.StatusNotFound)
-		return
+	response.Header().Set("Content-Type", "application/json")
+	response.Header().Set("X-Content-Type-Options", "nosniff")
+	response.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(response).Encode(stats); err != nil {
+		logger.Log.Debug("error encoding response", zap.Error(err))
+	}
+}
+
+// decodeJSON strictly decodes the body into v: unknown fields and anything
+// following the JSON value are rejected.
+func decodeJSON(body io.Reader, v any) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return err
+		}
+		return errTrailingData
 	}
+	return nil
+}
+
+func bodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func bodyErrorMessage(err error) string {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit)
+	}
+	return err.Error()
+}
 
-	http.Redirect(response, request, shortenURL.OriginalURL, http.StatusTemporaryRedirect)
+// validateLinkOptions checks the options of a new or updated link.
+func validateLinkOptions(linkOptions entity.LinkOptions) error {
+	if linkOptions.RedirectType != 0 && !slices.Contains(entity.RedirectTypes, linkOptions.RedirectType) {
+		return errUnsupportedRedirect
+	}
+	if linkOptions.MaxClicks < 0 {
+		return errNegativeMaxClicks
+	}
+
+	activeFrom, activeUntil := linkOptions.ActiveFrom, linkOptions.ActiveUntil
+	if !activeFrom.IsZero() && !activeUntil.IsZero() && !activeUntil.After(activeFrom) {
+		return errEmptyActiveWindow
+	}
+	return nil
+}
+
+// validateDescription checks the title and notes of a link.
+func validateDescription(title, notes string) error {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return errTitleTooLong
+	}
+	if utf8.RuneCountInString(notes) > maxNotesLength {
+		return errNotesTooLong
+	}
+	return nil
+}
+
+func utcOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// normalizeTags lowercases and trims tags, dropping empty and repeated
+// ones. The result is sorted.
+func normalizeTags(tags []string) ([]string, error) {
+	var normalizedTags []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(normalizedTags, tag) {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, errTagTooLong
+		}
+		normalizedTags = append(normalizedTags, tag)
+	}
+
+	if len(normalizedTags) > maxTags {
+		return nil, errTooManyTags
+	}
+	slices.Sort(normalizedTags)
+	return normalizedTags, nil
+}
+
+// normalizeURL validates a URL submitted for shortening and returns its
+// canonical form, which is what gets stored and compared for duplicates.
+func normalizeURL(originalURL string) (string, error) {
+	if len(originalURL) > config.MaxURLLength {
+		return "", errURLTooLong
+	}
+
+	normalizedURL, err := urlnorm.Normalize(originalURL, urlnorm.Options{
+		AllowedSchemes: config.AllowedSchemes,
+		AllowPrivate:   config.AllowPrivateTargets,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if BlockedDomains.Blocked(hostname(normalizedURL)) {
+		return "", errBlocked
+	}
+	return normalizedURL, nil
+}
+
+func hostname(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsedURL.Hostname()
+}
+
+// shorten assigns an ID to a new link and stores it. If the owner already
+// has a duplicate of it, see isDuplicate, the existing link is returned
+// instead. Duplicates are only found for the same owner: a client that
+// doesn't keep its auth cookie or use an API key is a new user on every
+// request and always gets a new link.
+func shorten(shortenURL entity.ShortenURL) (entity.ShortenURL, error) {
+	id, err := randstr.RandString(linkLength)
+	if err != nil {
+		return shortenURL, err
+	}
+
+	shortenURL.ID = id
+	shortenURL.Version = 1
+	requested := shortenURL
+	shortenURL, _, err = storage.Repository.StoreUnique(requested, func(existing entity.ShortenURL) bool {
+		return isDuplicate(existing, requested)
+	})
+	if errors.Is(err, entity.ErrWorkspaceNotFound) {
+		return shortenURL, err
+	}
+	if err != nil {
+		// Likely a collision happened
+		// TODO: handle collisions gracefully
+		return shortenURL, errStoreFailed
+	}
+
+	return shortenURL, nil
+}
+
+// isDuplicate reports whether requested, a link about to be created, would
+// be the same as existing, a link of the same owner for the same original
+// URL. They must be in the same workspace and have equal options, title,
+// notes and tags. Click-limited links are never duplicates, they are meant
+// to be handed out once.
+func isDuplicate(existing, requested entity.ShortenURL) bool {
+	return requested.MaxClicks == 0 &&
+		existing.WorkspaceID == requested.WorkspaceID &&
+		existing.LinkOptions == requested.LinkOptions &&
+		existing.Title == requested.Title &&
+		existing.Notes == requested.Notes &&
+		slices.Equal(existing.Tags, requested.Tags)
+}
+
+func shortURL(id string) string {
+	return fmt.Sprintf("%s/%s", config.ExpandPath.String(), id)
+}
+
+// GetLinkStats lists the click counts of all links, most clicked first.
+// The campaign query parameter restricts it to links tagged with that
+// utm_campaign.
+func GetLinkStats(response http.ResponseWriter, request *http.Request) {
+	filterCampaign := request.URL.Query().Get("campaign")
+
+	linkStats := []models.LinkStats{}
+	for _, shortenURL := range storage.Repository.List() {
+		linkCampaign := campaign(shortenURL.OriginalURL)
+		if filterCampaign != "" && linkCampaign != filterCampaign {
+			continue
+		}
+
+		linkStats = append(linkStats, models.LinkStats{
+			ID:          shortenURL.ID,
+			ShortURL:    shortURL(shortenURL.ID),
+			OriginalURL: shortenURL.OriginalURL,
+			Campaign:    linkCampaign,
+			Clicks:      shortenURL.Clicks,
+			CreatedAt:   shortenURL.CreatedAt,
+		})
+	}
+
+	slices.SortFunc(linkStats, func(a, b models.LinkStats) int {
+		if a.Clicks != b.Clicks {
+			return cmp.Compare(b.Clicks, a.Clicks)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	response.Header().Set("Content-Type", "application/json")
+	response.Header().Set("X-Content-Type-Options", "nosniff")
+	response.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(response).Encode(linkStats); err != nil {
+		logger.Log.Debug("error encoding response", zap.Error(err))
+	}
 }
 
 func JSONError(w http.ResponseWriter, error string, code int) {
@@ -8,9 +8,12 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/app/blocklist"
+	"github.com/leodayo/url-shortener/internal/app/cidr"
 	"github.com/leodayo/url-shortener/internal/app/config"
 	"github.com/leodayo/url-shortener/internal/app/storage"
 	"github.com/leodayo/url-shortener/internal/models"
@@ -35,9 +38,13 @@ func TestShortenURL(t *testing.T) {
 	}{
 		{name: "Valid http request", requestHost: strings.Replace(srv.URL, "https", "http", 1), requestMethod: http.MethodPost, requestBody: "http://example.com", expectedCode: http.StatusCreated},
 		{name: "Valid https request", requestHost: srv.URL, requestMethod: http.MethodPost, requestBody: "https://example.com", expectedCode: http.StatusCreated},
-		{name: "Bad request, body contains not a URL", requestHost: srv.URL, requestMethod: http.MethodPost, requestBody: "not a URL", expectedCode: http.StatusBadRequest, expectedErrorMessage: "Invalid URL"},
+		{name: "Bad request, body contains not a URL", requestHost: srv.URL, requestMethod: http.MethodPost, requestBody: "not a URL", expectedCode: http.StatusBadRequest, expectedErrorMessage: "invalid URL"},
+		{name: "Bad request, scheme not allowed", requestHost: srv.URL, requestMethod: http.MethodPost, requestBody: "javascript:alert(1)", expectedCode: http.StatusBadRequest, expectedErrorMessage: "URL scheme not allowed"},
+		{name: "Bad request, loopback target", requestHost: srv.URL, requestMethod: http.MethodPost, requestBody: "http://localhost:8080/", expectedCode: http.StatusBadRequest, expectedErrorMessage: "URL points to a private or loopback address"},
 		{name: "Bad request, not supported method", requestHost: srv.URL, requestMethod: http.MethodGet, requestBody: "http://example.com", expectedCode: http.StatusMethodNotAllowed, expectedErrorMessage: ""},
 		{name: "Not found, wrong method", requestHost: srv.URL + "/some/deeper/path", requestMethod: http.MethodPost, requestBody: "http://example.com", expectedCode: http.StatusNotFound},
+		{name: "Bad request, URL too long", requestHost: srv.URL, requestMethod: http.MethodPost, requestBody: "https://example.com/" + strings.Repeat("a", config.MaxURLLength), expectedCode: http.StatusBadRequest, expectedErrorMessage: "maximum URL length exceeded"},
+		{name: "Request body too large", requestHost: srv.URL, requestMethod: http.MethodPost, requestBody: "https://example.com/" + strings.Repeat("a", int(config.MaxBodySize)), expectedCode: http.StatusRequestEntityTooLarge},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -86,6 +93,11 @@ func TestShortenURLJSON(t *testing.T) {
 		{name: "Bad request, body contains not a URL", requestHost: endpointURL, requestMethod: http.MethodPost, requestBody: "{\"Url\":\"not a URL\"}", expectedCode: http.StatusBadRequest},
 		{name: "Bad request, not supported method", requestHost: endpointURL, requestMethod: http.MethodGet, requestBody: "{\"Url\":\"http://example.com\"}", expectedCode: http.StatusMethodNotAllowed},
 		{name: "Not found, wrong method", requestHost: endpointURL + "/some/deeper/path", requestMethod: http.MethodPost, requestBody: "{\"Url\":\"http://example.com\"}", expectedCode: http.StatusNotFound},
+		{name: "Bad request, malformed JSON", requestHost: endpointURL, requestMethod: http.MethodPost, requestBody: "{\"url\":", expectedCode: http.StatusBadRequest},
+		{name: "Bad request, unknown field", requestHost: endpointURL, requestMethod: http.MethodPost, requestBody: "{\"url\":\"http://example.com\",\"extra\":1}", expectedCode: http.StatusBadRequest},
+		{name: "Bad request, trailing garbage", requestHost: endpointURL, requestMethod: http.MethodPost, requestBody: "{\"url\":\"http://example.com\"} garbage", expectedCode: http.StatusBadRequest},
+		{name: "Bad request, URL too long", requestHost: endpointURL, requestMethod: http.MethodPost, requestBody: "{\"url\":\"https://example.com/" + strings.Repeat("a", config.MaxURLLength) + "\"}", expectedCode: http.StatusBadRequest},
+		{name: "Request body too large", requestHost: endpointURL, requestMethod: http.MethodPost, requestBody: "{\"url\":\"https://example.com/" + strings.Repeat("a", int(config.MaxBodySize)) + "\"}", expectedCode: http.StatusRequestEntityTooLarge},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -109,6 +121,96 @@ func TestShortenURLJSON(t *testing.T) {
 	}
 }
 
+func TestShortenDeduplication(t *testing.T) {
+	srv := newTestServer(t)
+	// Duplicates are per user, so the client needs its identity before
+	// sending concurrent requests.
+	client, _ := newUser(t, srv)
+
+	shorten := func(body string) (int, string) {
+		response, err := client.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(body).
+			Post(srv.URL + "/api/shorten")
+		require.NoError(t, err, "error making HTTP request")
+
+		var shortenResponse models.ShortenResponse
+		require.NoError(t, json.Unmarshal(response.Body(), &shortenResponse))
+		return response.StatusCode(), shortenResponse.Result
+	}
+
+	t.Run("concurrent requests create one link", func(t *testing.T) {
+		const requests = 20
+		statuses := make([]int, requests)
+		results := make([]string, requests)
+
+		var wg sync.WaitGroup
+		for i := range requests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				statuses[i], results[i] = shorten(`{"url":"https://example.com/concurrent","tags":["b","a"]}`)
+			}()
+		}
+		wg.Wait()
+
+		for i, result := range results {
+			assert.Equal(t, http.StatusCreated, statuses[i], "duplicates keep the status of a new link")
+			assert.Equal(t, results[0], result, "duplicates should point at the same link")
+		}
+	})
+
+	status, first := shorten(`{"url":"https://example.com/metadata","title":"Launch","tags":["a"]}`)
+	require.Equal(t, http.StatusCreated, status)
+
+	tests := []struct {
+		name      string
+		body      string
+		duplicate bool
+		alwaysNew bool
+	}{
+		{name: "same fields", body: `{"url":"https://example.com/metadata","title":"Launch","tags":["A"]}`, duplicate: true},
+		{name: "same URL after normalization", body: `{"url":"HTTPS://Example.com:443/metadata","title":"Launch","tags":["a"]}`, duplicate: true},
+		{name: "other title", body: `{"url":"https://example.com/metadata","title":"Relaunch","tags":["a"]}`},
+		{name: "other notes", body: `{"url":"https://example.com/metadata","title":"Launch","notes":"draft","tags":["a"]}`},
+		{name: "other tags", body: `{"url":"https://example.com/metadata","title":"Launch"}`},
+		{name: "other options", body: `{"url":"https://example.com/metadata","title":"Launch","tags":["a"],"redirect_type":301}`},
+		{name: "click-limited", body: `{"url":"https://example.com/metadata","title":"Launch","tags":["a"],"max_clicks":1}`, alwaysNew: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, result := shorten(tt.body)
+			assert.Equal(t, http.StatusCreated, status)
+			if tt.duplicate {
+				assert.Equal(t, first, result)
+			} else {
+				assert.NotEqual(t, first, result)
+			}
+
+			status, again := shorten(tt.body)
+			assert.Equal(t, http.StatusCreated, status)
+			if tt.alwaysNew {
+				assert.NotEqual(t, result, again)
+				return
+			}
+			assert.Equal(t, result, again, "repeating the request should find its link")
+		})
+	}
+
+	// Callers without an identity get a new one on every request, so
+	// their links are never duplicates of each other.
+	t.Run("callers without identity", func(t *testing.T) {
+		var results []string
+		for range 2 {
+			response, err := resty.New().R().SetBody("https://example.com/anonymous").Post(srv.URL)
+			require.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, http.StatusCreated, response.StatusCode())
+			results = append(results, string(response.Body()))
+		}
+		assert.NotEqual(t, results[0], results[1])
+	})
+}
+
 func TestGetOriginalURL(t *testing.T) {
 	storage.ItinInMemoryStorage()
 	srv := httptest.NewServer(MainRouter())
@@ -173,3 +275,61 @@ func TestGetOriginalURL(t *testing.T) {
 		})
 	}
 }
+
+func TestGetStats(t *testing.T) {
+	storage.ItinInMemoryStorage()
+	srv := httptest.NewServer(MainRouter())
+	defer srv.Close()
+	endpointURL := srv.URL + "/api/admin/stats"
+
+	response, err := resty.New().R().Get(endpointURL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusForbidden, response.StatusCode(), "expected admin routes to be closed without a trusted subnet")
+
+	trustedSubnet, err := cidr.Parse("127.0.0.0/8,::1")
+	require.NoError(t, err)
+	config.TrustedSubnet = trustedSubnet
+	defer func() { config.TrustedSubnet = nil }()
+
+	_, err = resty.New().R().SetBody("https://example.com").Post(srv.URL)
+	require.NoError(t, err, "error making HTTP request")
+
+	response, err = resty.New().R().Get(endpointURL)
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+
+	var stats models.StatsResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &stats))
+	assert.Equal(t, 1, stats.URLs)
+}
+
+func TestBlockedDomains(t *testing.T) {
+	storage.ItinInMemoryStorage()
+	srv := httptest.NewServer(MainRouter())
+	defer srv.Close()
+
+	parsedServerURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	config.ExpandPath.Host = parsedServerURL.Host
+	config.ExpandPath.Scheme = parsedServerURL.Scheme
+
+	response, err := resty.New().R().SetBody("https://login.evil.example/account").Post(srv.URL)
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusCreated, response.StatusCode())
+	shortenedURL := strings.TrimSpace(string(response.Body()))
+
+	BlockedDomains.Store(blocklist.Parse([]byte("evil.example\n")))
+	defer BlockedDomains.Store(nil)
+
+	response, err = resty.New().R().SetBody("https://www.evil.example/").Post(srv.URL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode())
+	assert.Equal(t, "URL domain is blocked", strings.TrimSpace(string(response.Body())))
+
+	client := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+	response, err = client.R().Get(shortenedURL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusForbidden, response.StatusCode())
+	assert.Empty(t, response.Header().Get("Location"))
+	assert.Contains(t, string(response.Body()), "login.evil.example")
+}
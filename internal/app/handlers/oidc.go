@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/oidc"
+	"github.com/leodayo/url-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	flowCookieName   = "oidc_flow"
+	flowCookiePath   = "/auth"
+	flowCookieMaxAge = 10 * time.Minute
+	oidcUserPrefix   = "oidc:"
+	// flowValuePrefix keeps signed flow cookies and identity cookies from
+	// being valid in place of each other.
+	flowValuePrefix = "oidc-flow:"
+)
+
+var (
+	errOIDCNotConfigured = errors.New("sign in is not configured")
+	errInvalidReturnTo   = errors.New("return_to must be a path on this service")
+	errSignInExpired     = errors.New("sign in expired or was started in another browser, try again")
+	errSignInFailed      = errors.New("sign in failed")
+)
+
+// OIDC is the issuer users sign in with, nil if sign in is disabled.
+var OIDC *oidc.Provider
+
+// loginFlow is kept in a signed cookie while the user signs in with the
+// issuer.
+type loginFlow struct {
+	oidc.Flow
+	ReturnTo string `json:"return_to"`
+}
+
+// Login sends the user to the issuer to sign in. After signing in the
+// user is sent back to the return_to path, / by default.
+func Login(response http.ResponseWriter, request *http.Request) {
+	if OIDC == nil {
+		JSONError(response, errOIDCNotConfigured.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	returnTo := request.URL.Query().Get("return_to")
+	if returnTo == "" {
+		returnTo = "/"
+	}
+	if !isLocalPath(returnTo) {
+		JSONError(response, errInvalidReturnTo.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flow, err := oidc.NewFlow()
+	if err != nil {
+		JSONError(response, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	value, err := json.Marshal(loginFlow{Flow: flow, ReturnTo: returnTo})
+	if err != nil {
+		JSONError(response, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(response, &http.Cookie{
+		Name:     flowCookieName,
+		Value:    auth.SignValue(flowValuePrefix + base64.RawURLEncoding.EncodeToString(value)),
+		Path:     flowCookiePath,
+		MaxAge:   int(flowCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   auth.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(response, request, OIDC.AuthCodeURL(flow), http.StatusFound)
+}
+
+// LoginCallback completes signing in once the issuer sends the user back.
+// The state must match the flow started by Login in the same browser.
+func LoginCallback(response http.ResponseWriter, request *http.Request) {
+	if OIDC == nil {
+		JSONError(response, errOIDCNotConfigured.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	flow, ok := readLoginFlow(request)
+	clearLoginFlow(response)
+
+	query := request.URL.Query()
+	if !ok || query.Get("state") != flow.State {
+		JSONError(response, errSignInExpired.Error(), http.StatusBadRequest)
+		return
+	}
+	if issuerError := query.Get("error"); issuerError != "" {
+		logger.Log.Debug("issuer refused sign in", zap.String("error", issuerError), zap.String("description", query.Get("error_description")))
+		JSONError(response, errSignInFailed.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := OIDC.Exchange(request.Context(), flow.Flow, query.Get("code"))
+	if err != nil {
+		logger.Log.Debug("cannot exchange authorization code", zap.Error(err))
+		JSONError(response, errSignInFailed.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	auth.StartSession(response, oidcUserPrefix+claims.Subject)
+	http.Redirect(response, request, flow.ReturnTo, http.StatusFound)
+}
+
+// Logout ends the session of the user and goes back to the UI. It is
+// posted by the UI form and like the other forms requires the CSRF token
+// of the user, so other sites cannot sign the user out.
+func Logout(response http.ResponseWriter, request *http.Request) {
+	auth.EndSession(response)
+	http.Redirect(response, request, "/ui", http.StatusSeeOther)
+}
+
+func readLoginFlow(request *http.Request) (loginFlow, bool) {
+	var flow loginFlow
+
+	cookie, err := request.Cookie(flowCookieName)
+	if err != nil {
+		return flow, false
+	}
+	value, ok := auth.VerifyValue(cookie.Value)
+	if !ok {
+		return flow, false
+	}
+	value, ok = strings.CutPrefix(value, flowValuePrefix)
+	if !ok {
+		return flow, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(data, &flow) != nil || flow.State == "" || !isLocalPath(flow.ReturnTo) {
+		return flow, false
+	}
+	return flow, true
+}
+
+func clearLoginFlow(response http.ResponseWriter) {
+	http.SetCookie(response, &http.Cookie{
+		Name:     flowCookieName,
+		Path:     flowCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   auth.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// isLocalPath reports whether path stays on this service when redirected
+// to, rejecting scheme-relative and backslash forms browsers treat as
+// another host.
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.ContainsAny(path, "\\\r\n")
+}
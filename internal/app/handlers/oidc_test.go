@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/oidc"
+	"github.com/leodayo/url-shortener/internal/app/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	srv := newTestServer(t)
+	issuer := oidctest.NewIssuer(t, "shortener")
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:      issuer.URL,
+		ClientID:    issuer.ClientID,
+		RedirectURL: srv.URL + "/auth/callback",
+	}, nil)
+	require.NoError(t, err)
+	OIDC = provider
+	t.Cleanup(func() { OIDC = nil })
+
+	t.Run("signs in and returns", func(t *testing.T) {
+		client := noRedirectClient()
+
+		response, err := client.R().Get(srv.URL + "/auth/login?return_to=/api/user/urls")
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusFound, response.StatusCode())
+
+		response, err = client.R().Get(response.Header().Get("Location"))
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusFound, response.StatusCode())
+
+		response, err = client.R().Get(response.Header().Get("Location"))
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusFound, response.StatusCode(), string(response.Body()))
+		assert.Equal(t, "/api/user/urls", response.Header().Get("Location"))
+
+		var session *http.Cookie
+		for _, cookie := range response.Cookies() {
+			if cookie.Name == auth.CookieName {
+				session = cookie
+			}
+		}
+		require.NotNil(t, session, "no session cookie set")
+		userID, ok := auth.VerifyValue(session.Value)
+		assert.True(t, ok)
+		assert.Equal(t, "oidc:"+issuer.Subject, userID)
+		assert.Zero(t, session.MaxAge, "session cookie should not persist")
+	})
+
+	t.Run("rejects state from another flow", func(t *testing.T) {
+		client := noRedirectClient()
+
+		response, err := client.R().Get(srv.URL + "/auth/login")
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusFound, response.StatusCode())
+
+		response, err = client.R().Get(response.Header().Get("Location"))
+		require.NoError(t, err, "error making HTTP request")
+		callbackURL, err := url.Parse(response.Header().Get("Location"))
+		require.NoError(t, err)
+
+		query := callbackURL.Query()
+		query.Set("state", "forged")
+		callbackURL.RawQuery = query.Encode()
+
+		response, err = client.R().Get(callbackURL.String())
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode())
+	})
+
+	t.Run("rejects flow signed for another purpose", func(t *testing.T) {
+		client := noRedirectClient()
+
+		response, err := client.R().Get(srv.URL + "/auth/login")
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusFound, response.StatusCode())
+		var flowCookie *http.Cookie
+		for _, cookie := range response.Cookies() {
+			if cookie.Name == flowCookieName {
+				flowCookie = cookie
+			}
+		}
+		require.NotNil(t, flowCookie, "no flow cookie set")
+
+		response, err = client.R().Get(response.Header().Get("Location"))
+		require.NoError(t, err, "error making HTTP request")
+		callbackURL := response.Header().Get("Location")
+
+		// The same flow signed like an identity cookie, without the prefix.
+		value, ok := auth.VerifyValue(flowCookie.Value)
+		require.True(t, ok)
+		value, ok = strings.CutPrefix(value, flowValuePrefix)
+		require.True(t, ok, "flow cookie is not signed with its prefix")
+
+		response, err = noRedirectClient().R().
+			SetCookie(&http.Cookie{Name: flowCookieName, Value: auth.SignValue(value)}).
+			Get(callbackURL)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode())
+
+		response, err = noRedirectClient().R().
+			SetCookie(&http.Cookie{Name: flowCookieName, Value: flowCookie.Value}).
+			Get(callbackURL)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusFound, response.StatusCode(), string(response.Body()))
+	})
+
+	t.Run("rejects return_to on another host", func(t *testing.T) {
+		for _, returnTo := range []string{"https://evil.example", "//evil.example", "/\\evil.example"} {
+			response, err := noRedirectClient().R().SetQueryParam("return_to", returnTo).Get(srv.URL + "/auth/login")
+			require.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode(), returnTo)
+		}
+	})
+}
+
+func TestLogout(t *testing.T) {
+	srv := newTestServer(t)
+	client, userID := newUser(t, srv)
+	client.SetRedirectPolicy(resty.RedirectPolicyFunc(func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}))
+
+	response, err := client.R().Post(srv.URL + "/auth/logout")
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusForbidden, response.StatusCode())
+	assert.Empty(t, response.Cookies(), "session ended without a CSRF token")
+
+	response, err = client.R().
+		SetFormData(map[string]string{csrfField: auth.CSRFToken(userID)}).
+		Post(srv.URL + "/auth/logout")
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusSeeOther, response.StatusCode())
+	assert.Equal(t, "/ui", response.Header().Get("Location"))
+	require.Len(t, response.Cookies(), 1)
+	assert.Equal(t, auth.CookieName, response.Cookies()[0].Name)
+	assert.Negative(t, response.Cookies()[0].MaxAge)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+)
+
+var errPathNotForwarded = errors.New("path passthrough is not enabled for this link")
+
+// reservedParams control GetOriginalURL itself and are never forwarded.
+var reservedParams = map[string]bool{
+	previewParam: true,
+	confirmParam: true,
+}
+
+// destinationURL builds the URL a request for a short link redirects to,
+// appending the extra path and merging the query of the request when the
+// link opts into it.
+func destinationURL(request *http.Request, shortenURL entity.ShortenURL) (string, error) {
+	extraPath := extraPath(request)
+	forwardQuery := shortenURL.ForwardQuery && request.URL.RawQuery != ""
+	if extraPath == "" && !forwardQuery {
+		return shortenURL.OriginalURL, nil
+	}
+	if extraPath != "" && !shortenURL.ForwardPath {
+		return "", errPathNotForwarded
+	}
+
+	destination, err := url.Parse(shortenURL.OriginalURL)
+	if err != nil {
+		return "", err
+	}
+
+	if extraPath != "" {
+		if err := appendPath(destination, extraPath); err != nil {
+			return "", err
+		}
+	}
+
+	if forwardQuery {
+		destination.RawQuery = mergeQuery(destination.RawQuery, request.URL.RawQuery, config.QueryConflict)
+	}
+
+	return destination.String(), nil
+}
+
+// extraPath returns the escaped path following the short ID, if any.
+func extraPath(request *http.Request) string {
+	prefix := config.ExpandPath.Path + "/" + request.PathValue("id") + "/"
+	if extra, ok := strings.CutPrefix(request.URL.EscapedPath(), prefix); ok {
+		return extra
+	}
+	return ""
+}
+
+// appendPath adds escaped path segments to the URL path. Dot segments are
+// refused so that the result can't climb above the original path.
+func appendPath(destination *url.URL, extra string) error {
+	for _, segment := range strings.Split(extra, "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return err
+		}
+		if unescaped == "." || unescaped == ".." {
+			return errPathNotForwarded
+		}
+	}
+
+	rawPath := strings.TrimSuffix(destination.EscapedPath(), "/") + "/" + extra
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return err
+	}
+
+	destination.Path = path
+	destination.RawPath = rawPath
+	return nil
+}
+
+// mergeQuery appends the forwarded query to the original one, resolving
+// parameters present in both according to rule. The order and encoding of
+// both queries are kept.
+func mergeQuery(original, forwarded, rule string) string {
+	originalPairs := splitQuery(original)
+	originalKeys := make(map[string]bool)
+	for _, pair := range originalPairs {
+		originalKeys[pair.key] = true
+	}
+
+	var forwardedPairs []queryPair
+	forwardedKeys := make(map[string]bool)
+	for _, pair := range splitQuery(forwarded) {
+		if !reservedParams[pair.key] {
+			forwardedPairs = append(forwardedPairs, pair)
+			forwardedKeys[pair.key] = true
+		}
+	}
+
+	var merged []string
+	for _, pair := range originalPairs {
+		if rule == config.QueryConflictRequest && forwardedKeys[pair.key] {
+			continue
+		}
+		merged = append(merged, pair.raw)
+	}
+	for _, pair := range forwardedPairs {
+		if rule == config.QueryConflictLink && originalKeys[pair.key] {
+			continue
+		}
+		merged = append(merged, pair.raw)
+	}
+
+	return strings.Join(merged, "&")
+}
+
+type queryPair struct {
+	key string
+	raw string
+}
+
+func splitQuery(rawQuery string) []queryPair {
+	var pairs []queryPair
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(raw, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		pairs = append(pairs, queryPair{key: key, raw: raw})
+	}
+	return pairs
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeQuery(t *testing.T) {
+	tests := []struct {
+		rule          string
+		expectedQuery string
+	}{
+		{rule: config.QueryConflictLink, expectedQuery: "b=2&a=1&utm_source=x"},
+		{rule: config.QueryConflictRequest, expectedQuery: "b=2&a=3&utm_source=x"},
+		{rule: config.QueryConflictAppend, expectedQuery: "b=2&a=1&a=3&utm_source=x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			actualQuery := mergeQuery("b=2&a=1", "a=3&preview&utm_source=x", tt.rule)
+			assert.Equal(t, tt.expectedQuery, actualQuery)
+		})
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	srv := newTestServer(t)
+	forwardingURL := shortenJSON(t, srv, `{"url":"https://example.com/base/?lang=en","forward_path":true,"forward_query":true}`)
+	plainURL := shortenJSON(t, srv, `{"url":"https://example.com/plain?lang=en"}`)
+
+	tests := []struct {
+		name             string
+		requestURL       string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{name: "Path and query forwarded", requestURL: forwardingURL + "/docs/getting%20started?utm_source=x&lang=de", expectedCode: http.StatusTemporaryRedirect, expectedLocation: "https://example.com/base/docs/getting%20started?lang=en&utm_source=x"},
+		{name: "Trailing slash only", requestURL: forwardingURL + "/", expectedCode: http.StatusTemporaryRedirect, expectedLocation: "https://example.com/base/?lang=en"},
+		{name: "Dot segments refused", requestURL: forwardingURL + "/a/%2e%2e/%2e%2e/admin", expectedCode: http.StatusNotFound},
+		{name: "Path not forwarded", requestURL: plainURL + "/docs", expectedCode: http.StatusNotFound},
+		{name: "Query not forwarded", requestURL: plainURL + "?utm_source=x", expectedCode: http.StatusTemporaryRedirect, expectedLocation: "https://example.com/plain?lang=en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := noRedirectClient().R().Get(tt.requestURL)
+			require.NoError(t, err, "error making HTTP request")
+
+			assert.Equal(t, tt.expectedCode, response.StatusCode())
+			if tt.expectedLocation != "" {
+				assert.Equal(t, tt.expectedLocation, response.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestInterstitialPassthrough(t *testing.T) {
+	srv := newTestServer(t)
+	shortenedURL := shortenJSON(t, srv, `{"url":"https://example.com/base/","interstitial":true,"forward_path":true,"forward_query":true}`)
+
+	response, err := noRedirectClient().R().Get(shortenedURL + "/docs?utm_source=x&lang=de")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+
+	match := regexp.MustCompile(`<a href="([^"]+)"`).FindSubmatch(response.Body())
+	require.NotNil(t, match, "no continue link on the interstitial")
+	confirmURL, err := url.Parse(shortenedURL + "/docs")
+	require.NoError(t, err)
+	confirmURL, err = confirmURL.Parse(html.UnescapeString(string(match[1])))
+	require.NoError(t, err)
+
+	response, err = noRedirectClient().R().Get(confirmURL.String())
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusTemporaryRedirect, response.StatusCode())
+	assert.Equal(t, "https://example.com/base/docs?lang=de&utm_source=x", response.Header().Get("Location"),
+		"continuing should keep the forwarded query")
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/pages"
+	"github.com/leodayo/url-shortener/internal/app/throttle"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// passwordHeader lets API clients unlock a protected link without the form.
+	passwordHeader = "X-Link-Password"
+	passwordField  = "password"
+)
+
+// unlockLimiter throttles password attempts per link, it is set up by
+// MainRouter from the config.
+var unlockLimiter *throttle.Limiter
+
+type unlockPage struct {
+	ShortURL     string
+	Action       string
+	Interstitial bool
+	Error        string
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func renderUnlockForm(response http.ResponseWriter, request *http.Request, shortenURL entity.ShortenURL, status int, errorMessage string) {
+	pages.Render(response, status, "unlock.html", unlockPage{
+		ShortURL:     shortURL(shortenURL.ID),
+		Action:       request.URL.RequestURI(),
+		Interstitial: shortenURL.Interstitial,
+		Error:        errorMessage,
+	})
+}
+
+// checkPassword verifies the password of a protected link. On failure it
+// answers the request, with the unlock form if it came from one.
+func checkPassword(response http.ResponseWriter, request *http.Request, shortenURL entity.ShortenURL, password string, form bool) bool {
+	fail := func(status int, message string) bool {
+		if form {
+			renderUnlockForm(response, request, shortenURL, status, message)
+		} else {
+			http.Error(response, message, status)
+		}
+		return false
+	}
+
+	if ok, retryAfter := unlockLimiter.Allow(shortenURL.ID); !ok {
+		response.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return fail(http.StatusTooManyRequests, "Too many attempts, try again later")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(shortenURL.PasswordHash), []byte(password)); err != nil {
+		unlockLimiter.Fail(shortenURL.ID)
+		return fail(http.StatusForbidden, "Wrong password")
+	}
+
+	unlockLimiter.Reset(shortenURL.ID)
+	return true
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordProtectedLink(t *testing.T) {
+	srv := newTestServer(t)
+	originalURL := "https://example.com/secret"
+	shortenedURL := shortenJSON(t, srv, `{"url":"`+originalURL+`","password":"hunter2"}`)
+
+	response, err := noRedirectClient().R().Get(shortenedURL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.Empty(t, response.Header().Get("Location"))
+	assert.Contains(t, string(response.Body()), `name="password"`)
+	assert.NotContains(t, string(response.Body()), originalURL)
+
+	response, err = noRedirectClient().R().Get(shortenedURL + "+")
+	require.NoError(t, err, "error making HTTP request")
+	assert.NotContains(t, string(response.Body()), originalURL, "preview must not reveal a protected destination")
+
+	tests := []struct {
+		name             string
+		method           string
+		password         string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{name: "Header, wrong password", method: http.MethodGet, password: "wrong", expectedCode: http.StatusForbidden},
+		{name: "Header, right password", method: http.MethodGet, password: "hunter2", expectedCode: http.StatusTemporaryRedirect, expectedLocation: originalURL},
+		{name: "Form, wrong password", method: http.MethodPost, password: "wrong", expectedCode: http.StatusForbidden},
+		{name: "Form, right password", method: http.MethodPost, password: "hunter2", expectedCode: http.StatusSeeOther, expectedLocation: originalURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := noRedirectClient().R()
+			if tt.method == http.MethodGet {
+				request.SetHeader(passwordHeader, tt.password)
+			} else {
+				request.SetFormData(map[string]string{passwordField: tt.password})
+			}
+
+			response, err := request.Execute(tt.method, shortenedURL)
+			require.NoError(t, err, "error making HTTP request")
+
+			assert.Equal(t, tt.expectedCode, response.StatusCode())
+			assert.Equal(t, tt.expectedLocation, response.Header().Get("Location"))
+		})
+	}
+}
+
+func TestPasswordProtectedLinkNotCached(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, redirectType := range []string{"301", "308"} {
+		shortenedURL := shortenJSON(t, srv, `{"url":"https://example.com/secret/`+redirectType+`","password":"hunter2","redirect_type":`+redirectType+`}`)
+
+		response, err := noRedirectClient().R().SetHeader(passwordHeader, "hunter2").Get(shortenedURL)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, redirectType, strconv.Itoa(response.StatusCode()))
+		assert.Equal(t, "private, no-store", response.Header().Get("Cache-Control"))
+	}
+}
+
+func TestPasswordAttemptsThrottled(t *testing.T) {
+	srv := newTestServer(t)
+	shortenedURL := shortenJSON(t, srv, `{"url":"https://example.com/throttled","password":"hunter2"}`)
+
+	for i := 0; i < config.UnlockMaxAttempts; i++ {
+		response, err := noRedirectClient().R().SetHeader(passwordHeader, "wrong").Get(shortenedURL)
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusForbidden, response.StatusCode())
+	}
+
+	response, err := noRedirectClient().R().SetHeader(passwordHeader, "hunter2").Get(shortenedURL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode())
+	assert.NotEmpty(t, response.Header().Get("Retry-After"))
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/qrcode"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultQRSize   = 256
+	minQRSize       = 32
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+	// qrMaxAge is how long clients may reuse a QR code without asking
+	// again. It can't change unless the base URL of the service does.
+	qrMaxAge = 24 * 60 * 60
+)
+
+var (
+	errUnknownQRFormat = errors.New("format must be png or svg")
+	errQRSize          = fmt.Errorf("size must be between %d and %d", minQRSize, maxQRSize)
+	errUnknownQRLevel  = errors.New("ecc must be one of L, M, Q or H")
+	errQRMargin        = fmt.Errorf("margin must be between 0 and %d", maxQRMargin)
+)
+
+var qrContentTypes = map[string]string{
+	"png": "image/png",
+	"svg": "image/svg+xml",
+}
+
+// GetURLQR returns a QR code of the short URL of a link the user can view.
+// The format, size, ecc, margin, fg and bg parameters control the image.
+// Codes only depend on them and the short URL, so the ETag is computed
+// without rendering and answers If-None-Match without rendering either.
+func GetURLQR(response http.ResponseWriter, request *http.Request) {
+	shortenURL, ok := storage.Repository.Retrieve(request.PathValue("id"))
+	if !ok {
+		JSONError(response, entity.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	userID := auth.UserID(request.Context())
+	if err := checkLinkAccess(shortenURL, userID, workspaceRoles(userID), entity.Role.CanView); err != nil {
+		JSONError(response, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	format, options, err := parseQROptions(request)
+	if err != nil {
+		JSONError(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	text := shortURL(shortenURL.ID)
+	etag := qrETag(text, format, options)
+	response.Header().Set("ETag", etag)
+	response.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", qrMaxAge))
+	if ifNoneMatch := request.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatchesWeak(ifNoneMatch, etag) {
+		response.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var buf bytes.Buffer
+	if format == "svg" {
+		err = qrcode.SVG(&buf, text, options)
+	} else {
+		err = qrcode.PNG(&buf, text, options)
+	}
+	if err != nil {
+		logger.Log.Error("cannot render QR code", zap.String("id", shortenURL.ID), zap.Error(err))
+		JSONError(response, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Content-Type", qrContentTypes[format])
+	response.Header().Set("X-Content-Type-Options", "nosniff")
+	response.WriteHeader(http.StatusOK)
+	response.Write(buf.Bytes())
+}
+
+func parseQROptions(request *http.Request) (string, qrcode.Options, error) {
+	query := request.URL.Query()
+	options := qrcode.Options{Size: defaultQRSize, Margin: defaultQRMargin}
+
+	format := strings.ToLower(cmp.Or(query.Get("format"), "png"))
+	if _, ok := qrContentTypes[format]; !ok {
+		return "", options, errUnknownQRFormat
+	}
+
+	if size := query.Get("size"); size != "" {
+		parsedSize, err := strconv.Atoi(size)
+		if err != nil || parsedSize < minQRSize || parsedSize > maxQRSize {
+			return "", options, errQRSize
+		}
+		options.Size = parsedSize
+	}
+
+	level, ok := qrcode.Levels[strings.ToUpper(cmp.Or(query.Get("ecc"), "M"))]
+	if !ok {
+		return "", options, errUnknownQRLevel
+	}
+	options.Level = level
+
+	if margin := query.Get("margin"); margin != "" {
+		parsedMargin, err := strconv.Atoi(margin)
+		if err != nil || parsedMargin < 0 || parsedMargin > maxQRMargin {
+			return "", options, errQRMargin
+		}
+		options.Margin = parsedMargin
+	}
+
+	var err error
+	if options.Foreground, err = qrcode.ParseColor(cmp.Or(query.Get("fg"), "000000")); err != nil {
+		return "", options, fmt.Errorf("fg: %w", err)
+	}
+	if options.Background, err = qrcode.ParseColor(cmp.Or(query.Get("bg"), "ffffff")); err != nil {
+		return "", options, fmt.Errorf("bg: %w", err)
+	}
+
+	return format, options, nil
+}
+
+// qrETag identifies a code by everything it is rendered from, normalized
+// so that equivalent parameters share it. It is weak because SVG codes are
+// sent compressed or not with the same tag.
+func qrETag(text, format string, options qrcode.Options) string {
+	key := fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%d\x00%s\x00%s", text, format, options.Size, options.Level, options.Margin,
+		qrcode.Hex(options.Foreground), qrcode.Hex(options.Background))
+	sum := sha256.Sum256([]byte(key))
+	return `W/"qr-` + hex.EncodeToString(sum[:12]) + `"`
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetURLQR(t *testing.T) {
+	srv := newTestServer(t)
+
+	owner := resty.New()
+	response, err := owner.R().SetBody("https://example.com/poster").Post(srv.URL)
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusCreated, response.StatusCode())
+	shortenedURL := string(response.Body())
+	endpointURL := srv.URL + "/api/urls/" + shortenedURL[strings.LastIndex(shortenedURL, "/")+1:] + "/qr"
+
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		contentType string
+	}{
+		{name: "default png", wantStatus: http.StatusOK, contentType: "image/png"},
+		{name: "svg", query: "format=svg&ecc=h&margin=0&fg=%23336699&bg=fff", wantStatus: http.StatusOK, contentType: "image/svg+xml"},
+		{name: "unknown format", query: "format=gif", wantStatus: http.StatusBadRequest},
+		{name: "size too large", query: "size=100000", wantStatus: http.StatusBadRequest},
+		{name: "unknown ecc", query: "ecc=X", wantStatus: http.StatusBadRequest},
+		{name: "negative margin", query: "margin=-1", wantStatus: http.StatusBadRequest},
+		{name: "invalid color", query: "fg=red", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := owner.R().SetQueryString(tt.query).Get(endpointURL)
+			require.NoError(t, err, "error making HTTP request")
+			require.Equal(t, tt.wantStatus, response.StatusCode(), string(response.Body()))
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.contentType, response.Header().Get("Content-Type"))
+			assert.NotEmpty(t, response.Header().Get("ETag"))
+		})
+	}
+
+	t.Run("scales to size", func(t *testing.T) {
+		response, err := owner.R().SetQueryParam("size", "512").Get(endpointURL)
+		require.NoError(t, err, "error making HTTP request")
+		img, err := png.Decode(bytes.NewReader(response.Body()))
+		require.NoError(t, err)
+		assert.LessOrEqual(t, img.Bounds().Dx(), 512)
+		assert.Greater(t, img.Bounds().Dx(), 256)
+	})
+
+	t.Run("revalidates with ETag", func(t *testing.T) {
+		response, err := owner.R().Get(endpointURL)
+		require.NoError(t, err, "error making HTTP request")
+		etag := response.Header().Get("ETag")
+
+		response, err = owner.R().SetHeader("If-None-Match", etag).SetQueryParam("ecc", "m").Get(endpointURL)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusNotModified, response.StatusCode(), "equivalent parameters should share the ETag")
+		assert.Empty(t, response.Body())
+
+		response, err = owner.R().SetHeader("If-None-Match", etag).SetQueryParam("ecc", "H").Get(endpointURL)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusOK, response.StatusCode())
+		assert.NotEqual(t, etag, response.Header().Get("ETag"))
+	})
+
+	t.Run("revalidates compressed SVG with a weak ETag", func(t *testing.T) {
+		response, err := owner.R().SetQueryParam("format", "svg").Get(endpointURL)
+		require.NoError(t, err, "error making HTTP request")
+		etag := response.Header().Get("ETag")
+		assert.True(t, strings.HasPrefix(etag, "W/"), "the ETag covers both encodings so it must be weak")
+
+		response, err = owner.R().SetQueryParam("format", "svg").SetHeader("Accept-Encoding", "identity").Get(endpointURL)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, etag, response.Header().Get("ETag"))
+
+		for _, ifNoneMatch := range []string{etag, strings.TrimPrefix(etag, "W/"), `"other", ` + etag} {
+			response, err = owner.R().SetQueryParam("format", "svg").SetHeader("If-None-Match", ifNoneMatch).Get(endpointURL)
+			require.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, http.StatusNotModified, response.StatusCode(), ifNoneMatch)
+		}
+	})
+
+	t.Run("only for users who can view the link", func(t *testing.T) {
+		response, err := resty.New().R().Get(endpointURL)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusForbidden, response.StatusCode())
+	})
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/pages"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+)
+
+// previewSuffix appended to a short ID shows the preview page instead of
+// redirecting, as does the preview query parameter.
+const previewSuffix = "+"
+
+// Query parameters understood by GetOriginalURL.
+const (
+	previewParam = "preview"
+	confirmParam = "confirm"
+)
+
+// redirectCacheControl tells browsers and proxies how long they may reuse
+// a redirect. Permanent redirects are meant for SEO and may be cached,
+// 302 is used for tracking links where every click has to reach us.
+var redirectCacheControl = map[int]string{
+	http.StatusMovedPermanently:  "public, max-age=86400",
+	http.StatusFound:             "no-store",
+	http.StatusTemporaryRedirect: "private, max-age=0",
+	http.StatusPermanentRedirect: "public, max-age=86400",
+}
+
+type linkPage struct {
+	ID          string
+	ShortURL    string
+	OriginalURL string
+	CreatedAt   time.Time
+	Clicks      int64
+}
+
+type interstitialPage struct {
+	linkPage
+	ConfirmURL string
+}
+
+// confirmURL is the request URL with confirmParam added, keeping the query
+// that passthrough links forward to the destination.
+func confirmURL(request *http.Request) string {
+	query := request.URL.Query()
+	query.Set(confirmParam, "1")
+	return "?" + query.Encode()
+}
+
+func newLinkPage(shortenURL entity.ShortenURL) linkPage {
+	return linkPage{
+		ID:          shortenURL.ID,
+		ShortURL:    shortURL(shortenURL.ID),
+		OriginalURL: shortenURL.OriginalURL,
+		CreatedAt:   shortenURL.CreatedAt,
+		Clicks:      shortenURL.Clicks,
+	}
+}
+
+func GetOriginalURL(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(response, "Not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortenURL, ok := retrieveLink(response, request)
+	if !ok {
+		return
+	}
+
+	if shortenURL.PasswordHash != "" {
+		password, ok := request.Header[passwordHeader]
+		if !ok {
+			renderUnlockForm(response, request, shortenURL, http.StatusOK, "")
+			return
+		}
+		if !checkPassword(response, request, shortenURL, password[0], false) {
+			return
+		}
+	}
+
+	followLink(response, request, shortenURL, false)
+}
+
+// UnlockURL accepts the password form of a protected link.
+func UnlockURL(response http.ResponseWriter, request *http.Request) {
+	shortenURL, ok := retrieveLink(response, request)
+	if !ok {
+		return
+	}
+
+	if shortenURL.PasswordHash == "" {
+		http.Error(response, "Not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	request.Body = http.MaxBytesReader(response, request.Body, config.MaxBodySize)
+	if err := request.ParseForm(); err != nil {
+		http.Error(response, bodyErrorMessage(err), bodyErrorStatus(err))
+		return
+	}
+
+	if !checkPassword(response, request, shortenURL, request.PostForm.Get(passwordField), true) {
+		return
+	}
+
+	followLink(response, request, shortenURL, true)
+}
+
+// retrieveLink looks up the requested link, answering the request itself
+// when the link is missing or its domain has been blocked.
+func retrieveLink(response http.ResponseWriter, request *http.Request) (entity.ShortenURL, bool) {
+	requestedID := strings.TrimSuffix(request.PathValue("id"), previewSuffix)
+	shortenURL, ok := storage.Repository.Retrieve(requestedID)
+	if !ok {
+		http.Error(response, "Link not found", http.StatusNotFound)
+		return shortenURL, false
+	}
+
+	if !shortenURL.ActiveAt(time.Now()) {
+		switch host := hostname(shortenURL.FallbackURL); {
+		case shortenURL.FallbackURL == "":
+			http.Error(response, "Link not found", http.StatusNotFound)
+		case BlockedDomains.Blocked(host):
+			pages.Render(response, http.StatusForbidden, "blocked.html", struct{ ID, Host string }{shortenURL.ID, host})
+		default:
+			response.Header().Set("Cache-Control", "no-store")
+			http.Redirect(response, request, shortenURL.FallbackURL, http.StatusFound)
+		}
+		return shortenURL, false
+	}
+
+	if shortenURL.ClicksExhausted() {
+		http.Error(response, "Link has expired", http.StatusGone)
+		return shortenURL, false
+	}
+
+	// The domain may have been blocked after the link was created.
+	if host := hostname(shortenURL.OriginalURL); BlockedDomains.Blocked(host) {
+		pages.Render(response, http.StatusForbidden, "blocked.html", struct{ ID, Host string }{shortenURL.ID, host})
+		return shortenURL, false
+	}
+
+	return shortenURL, true
+}
+
+// followLink shows the preview or interstitial page of an accessible link,
+// or redirects to its destination. After the unlock form has been posted,
+// the redirect is a 303 so that the form isn't resubmitted to the
+// destination, and the form counts as confirming the interstitial.
+func followLink(response http.ResponseWriter, request *http.Request, shortenURL entity.ShortenURL, unlocked bool) {
+	query := request.URL.Query()
+	if strings.HasSuffix(request.PathValue("id"), previewSuffix) || query.Has(previewParam) {
+		pages.Render(response, http.StatusOK, "preview.html", newLinkPage(shortenURL))
+		return
+	}
+
+	if shortenURL.Interstitial && !query.Has(confirmParam) && !unlocked {
+		pages.Render(response, http.StatusOK, "interstitial.html", interstitialPage{
+			linkPage:   newLinkPage(shortenURL),
+			ConfirmURL: confirmURL(request),
+		})
+		return
+	}
+
+	destination, err := destinationURL(request, shortenURL)
+	if err != nil {
+		http.Error(response, "Link not found", http.StatusNotFound)
+		return
+	}
+
+	// Another request may have used up the last click since retrieveLink.
+	if _, err := storage.Repository.RecordClick(shortenURL.ID); err != nil {
+		if errors.Is(err, entity.ErrClickLimitReached) {
+			http.Error(response, "Link has expired", http.StatusGone)
+		} else {
+			http.Error(response, "Link not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	if unlocked {
+		response.Header().Set("Cache-Control", "no-store")
+		http.Redirect(response, request, destination, http.StatusSeeOther)
+		return
+	}
+	redirect(response, request, shortenURL, destination)
+}
+
+// redirect sends the client to destination with the link's redirect type.
+func redirect(response http.ResponseWriter, request *http.Request, shortenURL entity.ShortenURL, destination string) {
+	status := shortenURL.RedirectType
+	if status == 0 {
+		status = entity.DefaultRedirectType
+	}
+
+	cacheControl := redirectCacheControl[status]
+	switch {
+	case shortenURL.PasswordHash != "":
+		// A cached redirect would skip the password check for everyone
+		// behind the same cache.
+		cacheControl = "private, no-store"
+	case shortenURL.MaxClicks > 0:
+		// Every click has to reach us to be counted against the limit.
+		cacheControl = "no-store"
+	case !shortenURL.ActiveFrom.IsZero() || !shortenURL.ActiveUntil.IsZero():
+		// A cached redirect would outlive the active window.
+		cacheControl = "no-store"
+	}
+	response.Header().Set("Cache-Control", cacheControl)
+	http.Redirect(response, request, destination, status)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/app/blocklist"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer starts the router on a fresh in-memory storage and points
+// config.ExpandPath at it so that returned short URLs can be requested.
+func newTestServer(t *testing.T) *httptest.Server {
+	storage.ItinInMemoryStorage()
+	srv := httptest.NewServer(MainRouter())
+	t.Cleanup(srv.Close)
+
+	parsedServerURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	config.ExpandPath.Host = parsedServerURL.Host
+	config.ExpandPath.Scheme = parsedServerURL.Scheme
+
+	return srv
+}
+
+// shortenJSON creates a link through /api/shorten and returns its short URL.
+func shortenJSON(t *testing.T, srv *httptest.Server, requestBody string) string {
+	response, err := resty.New().R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(requestBody).
+		Post(srv.URL + "/api/shorten")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusCreated, response.StatusCode(), "failed to create shortened URL: %s", response.Body())
+
+	var shortenResponse models.ShortenResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &shortenResponse))
+	return shortenResponse.Result
+}
+
+func noRedirectClient() *resty.Client {
+	return resty.New().SetRedirectPolicy(resty.RedirectPolicyFunc(func(req *http.Request, via []*http.Request) error {
+		// Prevent auto redirect
+		return http.ErrUseLastResponse
+	}))
+}
+
+func TestPreview(t *testing.T) {
+	srv := newTestServer(t)
+	originalURL := "https://example.com/?q=<script>"
+	shortenedURL := shortenJSON(t, srv, `{"url":"`+originalURL+`"}`)
+
+	response, err := noRedirectClient().R().Get(shortenedURL)
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusTemporaryRedirect, response.StatusCode())
+
+	for _, previewURL := range []string{shortenedURL + "+", shortenedURL + "?preview"} {
+		t.Run(previewURL, func(t *testing.T) {
+			response, err := noRedirectClient().R().Get(previewURL)
+			require.NoError(t, err, "error making HTTP request")
+
+			assert.Equal(t, http.StatusOK, response.StatusCode())
+			assert.Contains(t, response.Header().Get("Content-Type"), "text/html")
+			body := string(response.Body())
+			assert.Contains(t, body, "https://example.com/?q=&lt;script&gt;")
+			assert.NotContains(t, body, "<script>")
+			assert.Contains(t, body, "<dd>1</dd>", "expected one recorded click")
+		})
+	}
+}
+
+func TestInterstitial(t *testing.T) {
+	srv := newTestServer(t)
+	originalURL := "https://example.com/download"
+	shortenedURL := shortenJSON(t, srv, `{"url":"`+originalURL+`","interstitial":true}`)
+
+	response, err := noRedirectClient().R().Get(shortenedURL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.Contains(t, string(response.Body()), "You are leaving")
+
+	response, err = noRedirectClient().R().Get(shortenedURL + "?confirm=1")
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusTemporaryRedirect, response.StatusCode())
+	assert.Equal(t, originalURL, response.Header().Get("Location"))
+
+	// A plain link to the same URL is not a duplicate of the interstitial one.
+	plainURL := shortenJSON(t, srv, `{"url":"`+originalURL+`"}`)
+	assert.NotEqual(t, shortenedURL, plainURL)
+}
+
+func TestRedirectType(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name                 string
+		requestBody          string
+		expectedCode         int
+		expectedCacheControl string
+	}{
+		{name: "Default", requestBody: `{"url":"https://example.com/default"}`, expectedCode: http.StatusTemporaryRedirect, expectedCacheControl: "private, max-age=0"},
+		{name: "Moved permanently", requestBody: `{"url":"https://example.com/301","redirect_type":301}`, expectedCode: http.StatusMovedPermanently, expectedCacheControl: "public, max-age=86400"},
+		{name: "Found", requestBody: `{"url":"https://example.com/302","redirect_type":302}`, expectedCode: http.StatusFound, expectedCacheControl: "no-store"},
+		{name: "Temporary redirect", requestBody: `{"url":"https://example.com/307","redirect_type":307}`, expectedCode: http.StatusTemporaryRedirect, expectedCacheControl: "private, max-age=0"},
+		{name: "Permanent redirect", requestBody: `{"url":"https://example.com/308","redirect_type":308}`, expectedCode: http.StatusPermanentRedirect, expectedCacheControl: "public, max-age=86400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortenedURL := shortenJSON(t, srv, tt.requestBody)
+
+			response, err := noRedirectClient().R().Get(shortenedURL)
+			require.NoError(t, err, "error making HTTP request")
+
+			assert.Equal(t, tt.expectedCode, response.StatusCode())
+			assert.Equal(t, tt.expectedCacheControl, response.Header().Get("Cache-Control"))
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		response, err := resty.New().R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(`{"url":"https://example.com/300","redirect_type":300}`).
+			Post(srv.URL + "/api/shorten")
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode())
+	})
+}
+
+func TestMaxClicks(t *testing.T) {
+	srv := newTestServer(t)
+	requestBody := `{"url":"https://example.com/download","max_clicks":2}`
+	shortenedURL := shortenJSON(t, srv, requestBody)
+
+	for _, expectedCode := range []int{http.StatusTemporaryRedirect, http.StatusTemporaryRedirect, http.StatusGone, http.StatusGone} {
+		response, err := noRedirectClient().R().Get(shortenedURL)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, expectedCode, response.StatusCode())
+	}
+
+	// A click-limited link is never handed out twice.
+	assert.NotEqual(t, shortenedURL, shortenJSON(t, srv, requestBody))
+
+	t.Run("Permanent redirects not cached", func(t *testing.T) {
+		for _, redirectType := range []string{"301", "308"} {
+			shortenedURL := shortenJSON(t, srv, `{"url":"https://example.com/once/`+redirectType+`","max_clicks":1,"redirect_type":`+redirectType+`}`)
+
+			response, err := noRedirectClient().R().Get(shortenedURL)
+			require.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, redirectType, strconv.Itoa(response.StatusCode()))
+			assert.Equal(t, "no-store", response.Header().Get("Cache-Control"))
+		}
+	})
+}
+
+func TestActiveWindow(t *testing.T) {
+	srv := newTestServer(t)
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour).Format(time.RFC3339)
+	future := now.Add(time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name             string
+		requestBody      string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{name: "Within window", requestBody: `{"url":"https://example.com/now","active_from":"` + past + `","active_until":"` + future + `"}`, expectedCode: http.StatusTemporaryRedirect, expectedLocation: "https://example.com/now"},
+		{name: "Not active yet", requestBody: `{"url":"https://example.com/launch","active_from":"` + future + `"}`, expectedCode: http.StatusNotFound},
+		{name: "No longer active", requestBody: `{"url":"https://example.com/sale","active_until":"` + past + `"}`, expectedCode: http.StatusNotFound},
+		{name: "Not active yet with fallback", requestBody: `{"url":"https://example.com/launch","active_from":"` + future + `","fallback_url":"https://example.com/coming-soon"}`, expectedCode: http.StatusFound, expectedLocation: "https://example.com/coming-soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortenedURL := shortenJSON(t, srv, tt.requestBody)
+
+			response, err := noRedirectClient().R().Get(shortenedURL)
+			require.NoError(t, err, "error making HTTP request")
+
+			assert.Equal(t, tt.expectedCode, response.StatusCode())
+			assert.Equal(t, tt.expectedLocation, response.Header().Get("Location"))
+		})
+	}
+
+	t.Run("Permanent redirects not cached", func(t *testing.T) {
+		for _, redirectType := range []string{"301", "308"} {
+			shortenedURL := shortenJSON(t, srv, `{"url":"https://example.com/sale/`+redirectType+`","active_until":"`+future+`","redirect_type":`+redirectType+`}`)
+
+			response, err := noRedirectClient().R().Get(shortenedURL)
+			require.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, redirectType, strconv.Itoa(response.StatusCode()))
+			assert.Equal(t, "no-store", response.Header().Get("Cache-Control"))
+		}
+	})
+
+	t.Run("Blocked fallback", func(t *testing.T) {
+		shortenedURL := shortenJSON(t, srv, `{"url":"https://example.com/later","active_from":"`+future+`","fallback_url":"https://fallback.evil.example/"}`)
+
+		BlockedDomains.Store(blocklist.Parse([]byte("evil.example\n")))
+		defer BlockedDomains.Store(nil)
+
+		response, err := noRedirectClient().R().Get(shortenedURL)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusForbidden, response.StatusCode())
+		assert.Empty(t, response.Header().Get("Location"))
+		assert.Contains(t, string(response.Body()), "fallback.evil.example")
+	})
+
+	t.Run("Empty window", func(t *testing.T) {
+		response, err := resty.New().R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(`{"url":"https://example.com/never","active_from":"` + future + `","active_until":"` + past + `"}`).
+			Post(srv.URL + "/api/shorten")
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode())
+	})
+}
@@ -4,18 +4,74 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/leodayo/url-shortener/internal/app/auth"
 	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
 	"github.com/leodayo/url-shortener/internal/app/middleware"
+	"github.com/leodayo/url-shortener/internal/app/throttle"
 )
 
 func MainRouter() http.Handler {
+	unlockLimiter = throttle.NewLimiter(config.UnlockMaxAttempts, config.UnlockAttemptsWindow)
+
 	r := chi.NewRouter()
 
-	r.Use(middleware.ResponseLogger, middleware.GzipMiddleware, middleware.RequestLogger)
+	r.Use(middleware.RealIP, middleware.ResponseLogger, middleware.CompressionMiddleware, middleware.RequestLogger, middleware.Denylist)
 
 	r.Get(config.ExpandPath.Path+"/{id}", GetOriginalURL)
-	r.Post("/", ShortenURL)
-	r.Post("/api/shorten", ShortenURLJSON)
+	r.Get(config.ExpandPath.Path+"/{id}/*", GetOriginalURL)
+	r.Post(config.ExpandPath.Path+"/{id}", UnlockURL)
+	r.Post(config.ExpandPath.Path+"/{id}/*", UnlockURL)
+
+	r.Get("/.well-known/jwks.json", GetJWKS)
+
+	r.Get("/auth/login", Login)
+	r.Get("/auth/callback", LoginCallback)
+
+	r.Group(func(r chi.Router) {
+		r.Use(auth.Middleware(JSONError))
+
+		r.With(csrfProtect).Post("/auth/logout", Logout)
+
+		read := r.With(auth.RequireScope(entity.ScopeRead, JSONError))
+		read.Get("/api/urls/{id}", GetURL)
+		read.Get("/api/urls/{id}/history", GetURLHistory)
+		read.Get("/api/urls/{id}/qr", GetURLQR)
+		read.Get("/api/user/urls", ListUserURLs)
+		read.Get("/api/workspaces", ListWorkspaces)
+		read.Get("/api/workspaces/{id}", GetWorkspace)
+		read.Post("/api/token", IssueToken)
+		read.Get("/ui", UIHome)
+		read.Get("/ui/links/{id}", UILink)
+
+		write := r.With(auth.RequireScope(entity.ScopeWrite, JSONError))
+		write.Post("/", ShortenURL)
+		write.Post("/api/shorten", ShortenURLJSON)
+		write.Patch("/api/urls/{id}", UpdateURL)
+		write.Delete("/api/urls/{id}", DeleteURL)
+		write.Post("/api/workspaces", CreateWorkspace)
+		write.Patch("/api/workspaces/{id}", RenameWorkspace)
+		write.Delete("/api/workspaces/{id}", DeleteWorkspace)
+		write.Put("/api/workspaces/{id}/members/{userID}", PutMember)
+		write.Delete("/api/workspaces/{id}/members/{userID}", DeleteMember)
+
+		form := write.With(csrfProtect)
+		form.Post("/ui/links", UICreateLink)
+		form.Post("/ui/links/{id}", UIUpdateLink)
+		form.Post("/ui/links/{id}/delete", UIDeleteLink)
+
+		admin := r.With(auth.RequireScope(entity.ScopeAdmin, JSONError))
+		admin.Post("/api/keys", CreateAPIKey)
+		admin.Get("/api/keys", ListAPIKeys)
+		admin.Delete("/api/keys/{id}", DeleteAPIKey)
+	})
+
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(middleware.TrustedSubnet)
+		r.Get("/stats", GetStats)
+		r.Get("/stats/links", GetLinkStats)
+		r.Get("/search", SearchLinks)
+	})
 
 	return r
 }
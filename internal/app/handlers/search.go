@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/models"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+var (
+	errEmptySearch   = errors.New("q must not be empty")
+	errInvalidLimit  = errors.New("limit must be between 1 and 100")
+	errInvalidOffset = errors.New("offset must not be negative")
+)
+
+// SearchLinks finds links by prefix or substring of their alias, title,
+// destination host or path, best match first. Results are paginated with
+// limit and offset.
+func SearchLinks(response http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+
+	q := query.Get("q")
+	if q == "" {
+		JSONError(response, errEmptySearch.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := intParam(query.Get("limit"), defaultSearchLimit)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		JSONError(response, errInvalidLimit.Error(), http.StatusBadRequest)
+		return
+	}
+
+	offset, err := intParam(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		JSONError(response, errInvalidOffset.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shortenURLs := storage.Repository.Search(q)
+
+	searchResponse := models.SearchResponse{
+		Total:   len(shortenURLs),
+		Results: []models.URLResponse{},
+	}
+	if offset < len(shortenURLs) {
+		// Admins search from trusted subnets without an identity, so no
+		// link is theirs.
+		for _, shortenURL := range shortenURLs[offset:min(offset+limit, len(shortenURLs))] {
+			searchResponse.Results = append(searchResponse.Results, newURLResponse(shortenURL, ""))
+		}
+	}
+
+	writeJSON(response, http.StatusOK, "", searchResponse)
+}
+
+func intParam(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
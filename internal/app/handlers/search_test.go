@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/app/cidr"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchLinks(t *testing.T) {
+	srv := newTestServer(t)
+
+	trustedSubnet, err := cidr.Parse("127.0.0.0/8,::1")
+	require.NoError(t, err)
+	config.TrustedSubnet = trustedSubnet
+	defer func() { config.TrustedSubnet = nil }()
+
+	shortenJSON(t, srv, `{"url":"https://example.com/pricing","title":"Pricing"}`)
+	shortenJSON(t, srv, `{"url":"https://example.com/guides/pricing-faq"}`)
+	shortenJSON(t, srv, `{"url":"https://example.com/about"}`)
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedTotal int
+		expectedURLs  []string
+	}{
+		{name: "Ranked", query: "?q=pricing", expectedCode: http.StatusOK, expectedTotal: 2, expectedURLs: []string{"https://example.com/pricing", "https://example.com/guides/pricing-faq"}},
+		{name: "Second page", query: "?q=pricing&limit=1&offset=1", expectedCode: http.StatusOK, expectedTotal: 2, expectedURLs: []string{"https://example.com/guides/pricing-faq"}},
+		{name: "Past the end", query: "?q=pricing&offset=5", expectedCode: http.StatusOK, expectedTotal: 2, expectedURLs: []string{}},
+		{name: "Missing query", query: "", expectedCode: http.StatusBadRequest},
+		{name: "Limit too large", query: "?q=pricing&limit=1000", expectedCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := resty.New().R().Get(srv.URL + "/api/admin/search" + tt.query)
+			require.NoError(t, err, "error making HTTP request")
+			require.Equal(t, tt.expectedCode, response.StatusCode())
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var searchResponse models.SearchResponse
+			require.NoError(t, json.Unmarshal(response.Body(), &searchResponse))
+			assert.Equal(t, tt.expectedTotal, searchResponse.Total)
+			urls := []string{}
+			for _, result := range searchResponse.Results {
+				urls = append(urls, result.OriginalURL)
+			}
+			assert.Equal(t, tt.expectedURLs, urls)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/jwt"
+	"github.com/leodayo/url-shortener/internal/models"
+)
+
+var (
+	errJWTNotConfigured = errors.New("JWT signing is not configured")
+	errTokenFromJWT     = errors.New("a JWT cannot be exchanged for another, use an API key or the auth cookie")
+)
+
+// IssueToken returns a JWT for the user with the scope of the request, so
+// that other services can verify who the user is through the JWKS. A JWT
+// can't be used to get one, or it could be renewed forever and outlive
+// the API key it was issued for.
+func IssueToken(response http.ResponseWriter, request *http.Request) {
+	if auth.ViaJWT(request.Context()) {
+		JSONError(response, errTokenFromJWT.Error(), http.StatusForbidden)
+		return
+	}
+
+	token, expiresAt, err := auth.IssueToken(auth.UserID(request.Context()), auth.Scope(request.Context()))
+	if errors.Is(err, jwt.ErrNoSigningKey) {
+		JSONError(response, errJWTNotConfigured.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		JSONError(response, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Cache-Control", "no-store")
+	writeJSON(response, http.StatusOK, "", models.TokenResponse{Token: token, ExpiresAt: expiresAt.UTC()})
+}
+
+// GetJWKS publishes the public keys verifying issued JWTs.
+func GetJWKS(response http.ResponseWriter, request *http.Request) {
+	jwks := jwt.JWKS{Keys: []jwt.JWK{}}
+	if auth.Tokens != nil {
+		jwks = auth.Tokens.JWKS()
+	}
+
+	response.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(response, http.StatusOK, "", jwks)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/jwt"
+	"github.com/leodayo/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTAuth(t *testing.T) {
+	srv := newTestServer(t)
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	auth.Tokens, err = jwt.NewKeySet(jwt.NewEd25519Key("k1", privateKey))
+	require.NoError(t, err)
+	defer func() { auth.Tokens = nil }()
+
+	user, userID := newUser(t, srv)
+	response, err := user.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`{"url":"https://example.com/mine"}`).
+		Post(srv.URL + "/api/shorten")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusCreated, response.StatusCode())
+
+	response, err = user.R().Post(srv.URL + "/api/token")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+	var tokenResponse models.TokenResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &tokenResponse))
+
+	response, err = resty.New().R().SetAuthToken(tokenResponse.Token).Get(srv.URL + "/api/user/urls")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+	var urlResponses []models.URLResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &urlResponses))
+	require.Len(t, urlResponses, 1)
+	assert.True(t, urlResponses[0].IsOwner)
+
+	// A JWT could otherwise be renewed forever.
+	response, err = resty.New().R().SetAuthToken(tokenResponse.Token).Post(srv.URL + "/api/token")
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusForbidden, response.StatusCode())
+
+	response, err = resty.New().R().Get(srv.URL + "/.well-known/jwks.json")
+	require.NoError(t, err, "error making HTTP request")
+	var jwks jwt.JWKS
+	require.NoError(t, json.Unmarshal(response.Body(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "k1", jwks.Keys[0].KeyID)
+
+	expired, err := auth.Tokens.Sign(jwt.Claims{
+		Subject:   userID,
+		Scope:     "read",
+		IssuedAt:  time.Now().Add(-2 * time.Hour).Unix(),
+		ExpiresAt: time.Now().Add(-time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		token         string
+		expectedError string
+	}{
+		{name: "Expired", token: expired, expectedError: "token expired"},
+		{name: "Invalid", token: "eyJhbGciOiJub25lIn0.e30.", expectedError: "invalid token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := resty.New().R().SetAuthToken(tt.token).Get(srv.URL + "/api/user/urls")
+			require.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, http.StatusUnauthorized, response.StatusCode())
+
+			var errorResponse models.ErrorResponse
+			require.NoError(t, json.Unmarshal(response.Body(), &errorResponse))
+			assert.Equal(t, tt.expectedError, errorResponse.Error)
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/pages"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+)
+
+const (
+	csrfField = "csrf_token"
+	// chartDays is how many days the click chart of a link shows.
+	chartDays = 30
+	// chartHeight is the height of the tallest bar in the chart.
+	chartHeight = 100
+)
+
+const csrfFailedMessage = "The form has expired, reload the page and try again"
+
+// uiPage is the data every page of the UI is rendered with.
+type uiPage struct {
+	Title         string
+	UserID        string
+	SignedIn      bool
+	SignInEnabled bool
+	CSRFToken     string
+	Error         string
+}
+
+type uiLinksPage struct {
+	uiPage
+	Form    uiLinkForm
+	Created *uiLink
+	Links   []uiLink
+}
+
+type uiLinkPage struct {
+	uiPage
+	Link         uiLink
+	CanEdit      bool
+	Form         uiLinkForm
+	Chart        []chartBar
+	ChartFrom    time.Time
+	ChartTo      time.Time
+	RecentClicks int64
+}
+
+type uiLink struct {
+	ID          string
+	ShortURL    string
+	OriginalURL string
+	Title       string
+	Tags        []string
+	Clicks      int64
+	CreatedAt   time.Time
+}
+
+// uiLinkForm holds the submitted values of a link form, so that they can
+// be shown again when they are rejected.
+type uiLinkForm struct {
+	URL   string
+	Title string
+	Notes string
+	Tags  string
+}
+
+type chartBar struct {
+	Day    time.Time
+	Clicks int64
+	X      int
+	Y      int
+	Height int
+}
+
+// csrfProtect rejects form submissions without the CSRF token of the user.
+func csrfProtect(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		request.Body = http.MaxBytesReader(response, request.Body, config.MaxBodySize)
+		if err := request.ParseForm(); err != nil {
+			http.Error(response, bodyErrorMessage(err), bodyErrorStatus(err))
+			return
+		}
+
+		if !auth.ValidCSRFToken(auth.UserID(request.Context()), request.PostForm.Get(csrfField)) {
+			http.Error(response, csrfFailedMessage, http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(response, request)
+	})
+}
+
+// UIHome shows the shorten form and the personal links of the user,
+// newest first.
+func UIHome(response http.ResponseWriter, request *http.Request) {
+	page := uiLinksPage{uiPage: newUIPage(request, "My links")}
+
+	if createdID := request.URL.Query().Get("created"); createdID != "" {
+		if shortenURL, ok := storage.Repository.Retrieve(createdID); ok && shortenURL.OwnerID == page.UserID {
+			created := newUILink(shortenURL)
+			page.Created = &created
+		}
+	}
+
+	renderLinks(response, http.StatusOK, page)
+}
+
+// UICreateLink shortens the URL of the form and goes back to the list.
+func UICreateLink(response http.ResponseWriter, request *http.Request) {
+	page := uiLinksPage{uiPage: newUIPage(request, "My links"), Form: readLinkForm(request)}
+
+	originalURL, tags, err := validateLinkForm(page.Form)
+	if err != nil {
+		page.Error = err.Error()
+		renderLinks(response, http.StatusBadRequest, page)
+		return
+	}
+
+	shortenURL, err := shorten(entity.ShortenURL{
+		OriginalURL: originalURL,
+		OwnerID:     page.UserID,
+		Title:       page.Form.Title,
+		Notes:       page.Form.Notes,
+		Tags:        tags,
+	})
+	if err != nil {
+		page.Error = "Something went wrong"
+		renderLinks(response, http.StatusInternalServerError, page)
+		return
+	}
+
+	http.Redirect(response, request, "/ui?created="+shortenURL.ID, http.StatusSeeOther)
+}
+
+// UILink shows a link the user can view with its clicks per day, and the
+// edit form if the user can edit it.
+func UILink(response http.ResponseWriter, request *http.Request) {
+	shortenURL, ok := storage.Repository.Retrieve(request.PathValue("id"))
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+
+	page := newUILinkPage(request, shortenURL)
+	if err := checkLinkAccess(shortenURL, page.UserID, workspaceRoles(page.UserID), entity.Role.CanView); err != nil {
+		http.Error(response, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	renderLink(response, http.StatusOK, page)
+}
+
+// UIUpdateLink saves the edit form of a link the user can edit.
+func UIUpdateLink(response http.ResponseWriter, request *http.Request) {
+	shortenURL, ok := storage.Repository.Retrieve(request.PathValue("id"))
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+	userID := auth.UserID(request.Context())
+	roles := workspaceRoles(userID)
+	if err := checkLinkAccess(shortenURL, userID, roles, entity.Role.CanEdit); err != nil {
+		http.Error(response, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	form := readLinkForm(request)
+	originalURL, tags, err := validateLinkForm(form)
+	if err != nil {
+		page := newUILinkPage(request, shortenURL)
+		page.Form = form
+		page.Error = err.Error()
+		renderLink(response, http.StatusBadRequest, page)
+		return
+	}
+
+	_, err = storage.Repository.Update(shortenURL.ID, func(e entity.ShortenURL) (entity.ShortenURL, error) {
+		if err := checkLinkAccess(e, userID, roles, entity.Role.CanEdit); err != nil {
+			return e, err
+		}
+		e = changeOriginalURL(e, originalURL, userID)
+		e.Title = form.Title
+		e.Notes = form.Notes
+		e.Tags = tags
+		e.Version++
+		return e, nil
+	})
+	if err != nil {
+		http.Error(response, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	http.Redirect(response, request, "/ui/links/"+shortenURL.ID, http.StatusSeeOther)
+}
+
+// UIDeleteLink deletes a link the user can edit and goes back to the list.
+func UIDeleteLink(response http.ResponseWriter, request *http.Request) {
+	userID := auth.UserID(request.Context())
+	roles := workspaceRoles(userID)
+
+	err := storage.Repository.DeleteIf(request.PathValue("id"), func(e entity.ShortenURL) error {
+		return checkLinkAccess(e, userID, roles, entity.Role.CanEdit)
+	})
+	if err != nil {
+		http.Error(response, err.Error(), linkErrorStatus(err))
+		return
+	}
+	http.Redirect(response, request, "/ui", http.StatusSeeOther)
+}
+
+func newUIPage(request *http.Request, title string) uiPage {
+	userID := auth.UserID(request.Context())
+	return uiPage{
+		Title:         title,
+		UserID:        userID,
+		SignedIn:      strings.HasPrefix(userID, oidcUserPrefix),
+		SignInEnabled: OIDC != nil,
+		CSRFToken:     auth.CSRFToken(userID),
+	}
+}
+
+func newUILinkPage(request *http.Request, shortenURL entity.ShortenURL) uiLinkPage {
+	page := uiLinkPage{
+		uiPage: newUIPage(request, cmp.Or(shortenURL.Title, "Link "+shortenURL.ID)),
+		Link:   newUILink(shortenURL),
+		Form: uiLinkForm{
+			URL:   shortenURL.OriginalURL,
+			Title: shortenURL.Title,
+			Notes: shortenURL.Notes,
+			Tags:  strings.Join(shortenURL.Tags, ", "),
+		},
+	}
+	page.CanEdit = checkLinkAccess(shortenURL, page.UserID, workspaceRoles(page.UserID), entity.Role.CanEdit) == nil
+	page.Chart, page.RecentClicks = clickChart(shortenURL.DailyClicks, time.Now())
+	page.ChartFrom, page.ChartTo = page.Chart[0].Day, page.Chart[len(page.Chart)-1].Day
+	return page
+}
+
+func renderLinks(response http.ResponseWriter, status int, page uiLinksPage) {
+	shortenURLs := storage.Repository.ListByOwner(page.UserID)
+	slices.SortFunc(shortenURLs, func(a, b entity.ShortenURL) int {
+		if c := linkOrders["created"](b, a); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	page.Links = make([]uiLink, 0, len(shortenURLs))
+	for _, shortenURL := range shortenURLs {
+		page.Links = append(page.Links, newUILink(shortenURL))
+	}
+	pages.Render(response, status, "links.html", page)
+}
+
+func renderLink(response http.ResponseWriter, status int, page uiLinkPage) {
+	pages.Render(response, status, "link.html", page)
+}
+
+// validateLinkForm checks the link form like the API checks its requests.
+// Tags are separated by commas.
+func validateLinkForm(form uiLinkForm) (originalURL string, tags []string, err error) {
+	if originalURL, err = normalizeURL(form.URL); err != nil {
+		return "", nil, err
+	}
+	if tags, err = normalizeTags(strings.Split(form.Tags, ",")); err != nil {
+		return "", nil, err
+	}
+	if err = validateDescription(form.Title, form.Notes); err != nil {
+		return "", nil, err
+	}
+	return originalURL, tags, nil
+}
+
+func readLinkForm(request *http.Request) uiLinkForm {
+	return uiLinkForm{
+		URL:   strings.TrimSpace(request.PostForm.Get("url")),
+		Title: strings.TrimSpace(request.PostForm.Get("title")),
+		Notes: request.PostForm.Get("notes"),
+		Tags:  request.PostForm.Get("tags"),
+	}
+}
+
+func newUILink(e entity.ShortenURL) uiLink {
+	return uiLink{
+		ID:          e.ID,
+		ShortURL:    shortURL(e.ID),
+		OriginalURL: e.OriginalURL,
+		Title:       e.Title,
+		Tags:        e.Tags,
+		Clicks:      e.Clicks,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+// clickChart lays out the clicks of the last chartDays days up to now as
+// bars scaled to the busiest day, and returns their total.
+func clickChart(dailyClicks []entity.DayClicks, now time.Time) ([]chartBar, int64) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	bars := make([]chartBar, chartDays)
+
+	var total, busiest int64
+	for i := range bars {
+		bars[i].Day = today.AddDate(0, 0, i-chartDays+1)
+		bars[i].X = i * 10
+		for _, day := range dailyClicks {
+			if day.Day.Equal(bars[i].Day) {
+				bars[i].Clicks = day.Clicks
+			}
+		}
+		total += bars[i].Clicks
+		busiest = max(busiest, bars[i].Clicks)
+	}
+
+	for i := range bars {
+		if busiest > 0 {
+			bars[i].Height = int(bars[i].Clicks * chartHeight / busiest)
+			if bars[i].Clicks > 0 {
+				bars[i].Height = max(bars[i].Height, 1)
+			}
+		}
+		bars[i].Y = chartHeight - bars[i].Height
+	}
+	return bars, total
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var csrfTokenPattern = regexp.MustCompile(`name="csrf_token" value="([0-9a-f]+)"`)
+
+// openUI loads the UI with the client and returns the CSRF token of its
+// forms.
+func openUI(t *testing.T, srv string, client *resty.Client) string {
+	response, err := client.R().Get(srv + "/ui")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+
+	match := csrfTokenPattern.FindSubmatch(response.Body())
+	require.NotNil(t, match, "no CSRF token in page")
+	return string(match[1])
+}
+
+func TestUI(t *testing.T) {
+	srv := newTestServer(t)
+	client := noRedirectClient()
+	csrfToken := openUI(t, srv.URL, client)
+
+	t.Run("rejects forms without CSRF token", func(t *testing.T) {
+		response, err := client.R().
+			SetFormData(map[string]string{"url": "https://example.com/forged"}).
+			Post(srv.URL + "/ui/links")
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusForbidden, response.StatusCode())
+
+		other := noRedirectClient()
+		openUI(t, srv.URL, other)
+		response, err = other.R().
+			SetFormData(map[string]string{"url": "https://example.com/forged", "csrf_token": csrfToken}).
+			Post(srv.URL + "/ui/links")
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusForbidden, response.StatusCode(), "token of another user should be rejected")
+	})
+
+	t.Run("shows form errors", func(t *testing.T) {
+		response, err := client.R().
+			SetFormData(map[string]string{"url": "not a url", "title": "Kept", "csrf_token": csrfToken}).
+			Post(srv.URL + "/ui/links")
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode())
+		assert.Contains(t, string(response.Body()), `value="Kept"`, "submitted values should be kept")
+	})
+
+	response, err := client.R().
+		SetFormData(map[string]string{"url": "https://example.com/docs", "title": "<Docs>", "tags": "Work, docs", "csrf_token": csrfToken}).
+		Post(srv.URL + "/ui/links")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusSeeOther, response.StatusCode(), string(response.Body()))
+
+	location, err := url.Parse(response.Header().Get("Location"))
+	require.NoError(t, err)
+	id := location.Query().Get("created")
+	require.NotEmpty(t, id)
+
+	response, err = client.R().Get(srv.URL + location.String())
+	require.NoError(t, err, "error making HTTP request")
+	body := string(response.Body())
+	assert.Contains(t, body, "Created")
+	assert.Contains(t, body, `data-copy="`+shortURL(id)+`"`)
+	assert.Contains(t, body, "&lt;Docs&gt;")
+	assert.Contains(t, body, `<span class="tag">work</span>`)
+
+	_, err = noRedirectClient().R().Get(shortURL(id))
+	require.NoError(t, err, "error making HTTP request")
+
+	t.Run("shows clicks per day", func(t *testing.T) {
+		response, err := client.R().Get(srv.URL + "/ui/links/" + id)
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusOK, response.StatusCode())
+		body := string(response.Body())
+		assert.Contains(t, body, "1 in total, 1 in the last 30 days")
+		assert.Equal(t, 30, strings.Count(body, "<rect "))
+		assert.Contains(t, body, `height="100"`, "the only day with clicks should be the tallest bar")
+	})
+
+	t.Run("hides links of other users", func(t *testing.T) {
+		response, err := noRedirectClient().R().Get(srv.URL + "/ui/links/" + id)
+		require.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusForbidden, response.StatusCode())
+	})
+
+	t.Run("edits link", func(t *testing.T) {
+		response, err := client.R().
+			SetFormData(map[string]string{"url": "https://example.com/manual", "title": "Manual", "notes": "moved", "csrf_token": csrfToken}).
+			Post(srv.URL + "/ui/links/" + id)
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusSeeOther, response.StatusCode(), string(response.Body()))
+
+		shortenURL, ok := storage.Repository.Retrieve(id)
+		require.True(t, ok)
+		assert.Equal(t, "https://example.com/manual", shortenURL.OriginalURL)
+		assert.Equal(t, "Manual", shortenURL.Title)
+		assert.Empty(t, shortenURL.Tags)
+		assert.Len(t, shortenURL.History, 1)
+		assert.Equal(t, int64(1), shortenURL.Clicks, "clicks should be kept")
+	})
+
+	t.Run("deletes link", func(t *testing.T) {
+		response, err := client.R().
+			SetFormData(map[string]string{"csrf_token": csrfToken}).
+			Post(srv.URL + "/ui/links/" + id + "/delete")
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusSeeOther, response.StatusCode())
+
+		_, ok := storage.Repository.Retrieve(id)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/logger"
+	"github.com/leodayo/url-shortener/internal/models"
+	"go.uber.org/zap"
+)
+
+var (
+	errLinkForbidden      = errors.New("not allowed to access this link")
+	errPreconditionFailed = errors.New("link has changed, fetch it again")
+)
+
+// GetURL returns a link the user can view.
+func GetURL(response http.ResponseWriter, request *http.Request) {
+	shortenURL, ok := storage.Repository.Retrieve(request.PathValue("id"))
+	if !ok {
+		JSONError(response, entity.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	userID := auth.UserID(request.Context())
+	if err := checkLinkAccess(shortenURL, userID, workspaceRoles(userID), entity.Role.CanView); err != nil {
+		JSONError(response, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	writeJSON(response, http.StatusOK, etag(shortenURL), newURLResponse(shortenURL, userID))
+}
+
+// UpdateURL changes the fields present in the request of a link the user
+// can edit, keeping its short ID. Changes of the original URL are kept in
+// the link history. With If-Match the change only applies if the link is
+// still at the version of the given ETag.
+func UpdateURL(response http.ResponseWriter, request *http.Request) {
+	if request.Header.Get("Content-Type") != "application/json" {
+		JSONError(response, "Content-Type not supported", http.StatusBadRequest)
+		return
+	}
+
+	var updateRequest models.UpdateURLRequest
+	if err := decodeJSON(http.MaxBytesReader(response, request.Body, config.MaxBodySize), &updateRequest); err != nil {
+		logger.Log.Debug("cannot decode request JSON body", zap.Error(err))
+		JSONError(response, bodyErrorMessage(err), bodyErrorStatus(err))
+		return
+	}
+
+	var originalURL, fallbackURL string
+	var tags []string
+	var err error
+	if updateRequest.URL != nil {
+		if originalURL, err = normalizeURL(*updateRequest.URL); err != nil {
+			JSONError(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if updateRequest.FallbackURL.Value != nil && *updateRequest.FallbackURL.Value != "" {
+		if fallbackURL, err = normalizeURL(*updateRequest.FallbackURL.Value); err != nil {
+			JSONError(response, "fallback_url: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if updateRequest.Tags != nil {
+		if tags, err = normalizeTags(*updateRequest.Tags); err != nil {
+			JSONError(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if updateRequest.Title != nil {
+		*updateRequest.Title = strings.TrimSpace(*updateRequest.Title)
+	}
+	if err := validateDescription(ptrOrZero(updateRequest.Title), ptrOrZero(updateRequest.Notes)); err != nil {
+		JSONError(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := auth.UserID(request.Context())
+	roles := workspaceRoles(userID)
+	ifMatch := request.Header.Get("If-Match")
+
+	shortenURL, err := storage.Repository.Update(request.PathValue("id"), func(e entity.ShortenURL) (entity.ShortenURL, error) {
+		if err := checkLinkAccess(e, userID, roles, entity.Role.CanEdit); err != nil {
+			return e, err
+		}
+		if ifMatch != "" && !etagMatches(ifMatch, etag(e)) {
+			return e, errPreconditionFailed
+		}
+
+		if updateRequest.URL != nil {
+			e = changeOriginalURL(e, originalURL, userID)
+		}
+		if updateRequest.RedirectType != nil {
+			e.RedirectType = *updateRequest.RedirectType
+		}
+		if updateRequest.Interstitial != nil {
+			e.Interstitial = *updateRequest.Interstitial
+		}
+		if updateRequest.MaxClicks != nil {
+			e.MaxClicks = *updateRequest.MaxClicks
+		}
+		if updateRequest.Title != nil {
+			e.Title = *updateRequest.Title
+		}
+		if updateRequest.Notes != nil {
+			e.Notes = *updateRequest.Notes
+		}
+		if updateRequest.Tags != nil {
+			e.Tags = tags
+		}
+		if updateRequest.ActiveFrom.Set {
+			e.ActiveFrom = utcOrZero(updateRequest.ActiveFrom.Value)
+		}
+		if updateRequest.ActiveUntil.Set {
+			e.ActiveUntil = utcOrZero(updateRequest.ActiveUntil.Value)
+		}
+		if updateRequest.FallbackURL.Set {
+			e.FallbackURL = fallbackURL
+		}
+
+		if err := validateLinkOptions(e.LinkOptions); err != nil {
+			return e, err
+		}
+		e.Version++
+		return e, nil
+	})
+	if err != nil {
+		JSONError(response, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	writeJSON(response, http.StatusOK, etag(shortenURL), newURLResponse(shortenURL, userID))
+}
+
+// DeleteURL deletes a link the user can edit. Like UpdateURL it honours
+// If-Match.
+func DeleteURL(response http.ResponseWriter, request *http.Request) {
+	userID := auth.UserID(request.Context())
+	roles := workspaceRoles(userID)
+	ifMatch := request.Header.Get("If-Match")
+
+	err := storage.Repository.DeleteIf(request.PathValue("id"), func(e entity.ShortenURL) error {
+		if err := checkLinkAccess(e, userID, roles, entity.Role.CanEdit); err != nil {
+			return err
+		}
+		if ifMatch != "" && !etagMatches(ifMatch, etag(e)) {
+			return errPreconditionFailed
+		}
+		return nil
+	})
+	if err != nil {
+		JSONError(response, err.Error(), linkErrorStatus(err))
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// GetURLHistory lists the changes of the original URL of a link the user
+// can view, oldest first.
+func GetURLHistory(response http.ResponseWriter, request *http.Request) {
+	shortenURL, ok := storage.Repository.Retrieve(request.PathValue("id"))
+	if !ok {
+		JSONError(response, entity.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	userID := auth.UserID(request.Context())
+	if err := checkLinkAccess(shortenURL, userID, workspaceRoles(userID), entity.Role.CanView); err != nil {
+		JSONError(response, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	history := make([]models.URLChange, 0, len(shortenURL.History))
+	for _, change := range shortenURL.History {
+		history = append(history, models.URLChange(change))
+	}
+
+	writeJSON(response, http.StatusOK, etag(shortenURL), history)
+}
+
+// changeOriginalURL points the link to originalURL, recording the change
+// in its history. The oldest changes beyond entity.MaxHistory are dropped.
+func changeOriginalURL(e entity.ShortenURL, originalURL, userID string) entity.ShortenURL {
+	if originalURL == e.OriginalURL {
+		return e
+	}
+
+	e.History = append(slices.Clip(e.History), entity.URLChange{
+		OldURL:    e.OriginalURL,
+		NewURL:    originalURL,
+		ChangedBy: userID,
+		ChangedAt: time.Now().UTC(),
+	})
+	if len(e.History) > entity.MaxHistory {
+		e.History = e.History[len(e.History)-entity.MaxHistory:]
+	}
+	e.OriginalURL = originalURL
+	return e
+}
+
+// checkLinkAccess checks the role of the user on the link with allowed.
+// Links in a workspace take the user's role in it, given by roles as
+// returned by workspaceRoles. Personal links are owned by their creator;
+// those created before owners were recorded belong to nobody.
+func checkLinkAccess(e entity.ShortenURL, userID string, roles map[string]entity.Role, allowed func(entity.Role) bool) error {
+	var role entity.Role
+	switch {
+	case e.WorkspaceID != "":
+		role = roles[e.WorkspaceID]
+	case e.OwnerID != "" && e.OwnerID == userID:
+		role = entity.RoleOwner
+	}
+
+	if !allowed(role) {
+		return errLinkForbidden
+	}
+	return nil
+}
+
+func linkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, entity.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errLinkForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, errUnsupportedRedirect), errors.Is(err, errNegativeMaxClicks), errors.Is(err, errEmptyActiveWindow):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// newURLResponse describes the link to the user. The owner is only told
+// apart, user IDs are not shown.
+func newURLResponse(e entity.ShortenURL, userID string) models.URLResponse {
+	urlResponse := models.URLResponse{
+		ID:           e.ID,
+		ShortURL:     shortURL(e.ID),
+		OriginalURL:  e.OriginalURL,
+		IsOwner:      e.OwnerID != "" && e.OwnerID == userID,
+		WorkspaceID:  e.WorkspaceID,
+		CreatedAt:    e.CreatedAt,
+		RedirectType: cmp.Or(e.RedirectType, entity.DefaultRedirectType),
+		Interstitial: e.Interstitial,
+		Protected:    e.PasswordHash != "",
+		Title:        e.Title,
+		Notes:        e.Notes,
+		Tags:         e.Tags,
+		Clicks:       e.Clicks,
+		MaxClicks:    e.MaxClicks,
+		FallbackURL:  e.FallbackURL,
+	}
+	if urlResponse.Tags == nil {
+		urlResponse.Tags = []string{}
+	}
+	if !e.ActiveFrom.IsZero() {
+		urlResponse.ActiveFrom = &e.ActiveFrom
+	}
+	if !e.ActiveUntil.IsZero() {
+		urlResponse.ActiveUntil = &e.ActiveUntil
+	}
+	return urlResponse
+}
+
+func ptrOrZero[T any](p *T) (v T) {
+	if p != nil {
+		v = *p
+	}
+	return v
+}
+
+func etag(e entity.ShortenURL) string {
+	return fmt.Sprintf(`"%s-%d"`, e.ID, e.Version)
+}
+
+// etagMatches reports whether the If-Match header lists the ETag. Weak
+// ETags never match, as If-Match requires strong comparison.
+func etagMatches(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatchesWeak reports whether the If-None-Match header lists the
+// ETag. If-None-Match uses weak comparison, so the W/ prefix is ignored
+// on both sides.
+func etagMatchesWeak(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(response http.ResponseWriter, status int, etag string, v any) {
+	response.Header().Set("Content-Type", "application/json")
+	response.Header().Set("X-Content-Type-Options", "nosniff")
+	if etag != "" {
+		response.Header().Set("ETag", etag)
+	}
+	response.WriteHeader(status)
+
+	if err := json.NewEncoder(response).Encode(v); err != nil {
+		logger.Log.Debug("error encoding response", zap.Error(err))
+	}
+}
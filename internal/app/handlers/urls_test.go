@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateURL(t *testing.T) {
+	srv := newTestServer(t)
+
+	owner := resty.New()
+	response, err := owner.R().SetBody("https://example.com/tpyo").Post(srv.URL)
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusCreated, response.StatusCode())
+	shortenedURL := string(response.Body())
+	id := shortenedURL[strings.LastIndex(shortenedURL, "/")+1:]
+	endpointURL := srv.URL + "/api/urls/" + id
+
+	patch := func(client *resty.Client, ifMatch string) *resty.Response {
+		request := client.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(`{"url":"https://example.com/typo"}`)
+		if ifMatch != "" {
+			request.SetHeader("If-Match", ifMatch)
+		}
+		response, err := request.Patch(endpointURL)
+		require.NoError(t, err, "error making HTTP request")
+		return response
+	}
+
+	response = patch(resty.New(), "")
+	assert.Equal(t, http.StatusForbidden, response.StatusCode(), "only the owner may update a link")
+
+	response = patch(owner, `"`+id+`-0"`)
+	assert.Equal(t, http.StatusPreconditionFailed, response.StatusCode())
+
+	response = patch(owner, `"`+id+`-1"`)
+	require.Equal(t, http.StatusOK, response.StatusCode(), string(response.Body()))
+	assert.Equal(t, `"`+id+`-2"`, response.Header().Get("ETag"))
+
+	var urlResponse models.URLResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &urlResponse))
+	assert.Equal(t, shortenedURL, urlResponse.ShortURL)
+	assert.Equal(t, "https://example.com/typo", urlResponse.OriginalURL)
+
+	response, err = noRedirectClient().R().Get(shortenedURL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, "https://example.com/typo", response.Header().Get("Location"))
+
+	response, err = owner.R().Get(endpointURL + "/history")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+
+	var history []models.URLChange
+	require.NoError(t, json.Unmarshal(response.Body(), &history))
+	require.Len(t, history, 1)
+	assert.Equal(t, "https://example.com/tpyo", history[0].OldURL)
+	assert.Equal(t, "https://example.com/typo", history[0].NewURL)
+	assert.NotEmpty(t, history[0].ChangedBy)
+}
+
+func TestURLResource(t *testing.T) {
+	srv := newTestServer(t)
+
+	owner := resty.New()
+	response, err := owner.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`{"url":"https://example.com/","redirect_type":301,"tags":["Docs"," docs","launch"],"active_until":"2099-01-01T00:00:00Z"}`).
+		Post(srv.URL + "/api/shorten")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusCreated, response.StatusCode())
+
+	var shortenResponse models.ShortenResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &shortenResponse))
+	id := shortenResponse.Result[strings.LastIndex(shortenResponse.Result, "/")+1:]
+	endpointURL := srv.URL + "/api/urls/" + id
+
+	response, err = owner.R().Get(endpointURL)
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+
+	var urlResponse models.URLResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &urlResponse))
+	assert.Equal(t, "https://example.com/", urlResponse.OriginalURL)
+	assert.Equal(t, http.StatusMovedPermanently, urlResponse.RedirectType)
+	assert.Equal(t, []string{"docs", "launch"}, urlResponse.Tags)
+	assert.True(t, urlResponse.IsOwner)
+	assert.False(t, urlResponse.CreatedAt.IsZero())
+	require.NotNil(t, urlResponse.ActiveUntil)
+
+	response, err = resty.New().R().Get(endpointURL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusForbidden, response.StatusCode())
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{name: "Unsupported redirect type", body: `{"redirect_type":303}`, expectedCode: http.StatusBadRequest},
+		{name: "Empty active window", body: `{"active_from":"2100-01-01T00:00:00Z"}`, expectedCode: http.StatusBadRequest},
+		{name: "Unknown field", body: `{"owner":"someone"}`, expectedCode: http.StatusBadRequest},
+		{name: "Tags and expiry cleared", body: `{"tags":[],"active_until":null}`, expectedCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := owner.R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(tt.body).
+				Patch(endpointURL)
+			require.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, tt.expectedCode, response.StatusCode(), string(response.Body()))
+		})
+	}
+
+	response, err = owner.R().Get(endpointURL)
+	require.NoError(t, err, "error making HTTP request")
+	urlResponse = models.URLResponse{}
+	require.NoError(t, json.Unmarshal(response.Body(), &urlResponse))
+	assert.Empty(t, urlResponse.Tags)
+	assert.Nil(t, urlResponse.ActiveUntil)
+	assert.Equal(t, http.StatusMovedPermanently, urlResponse.RedirectType, "rejected changes should not apply")
+
+	response, err = owner.R().Delete(endpointURL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode())
+
+	response, err = noRedirectClient().R().Get(shortenResponse.Result)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode())
+}
+
+func TestURLHistorySurvivesRestart(t *testing.T) {
+	srv := newTestServer(t)
+	config.FileStoragePath = filepath.Join(t.TempDir(), "storage.json")
+	require.NoError(t, storage.InitFileStorage())
+
+	owner := resty.New()
+	response, err := owner.R().SetBody("https://example.com/0").Post(srv.URL)
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusCreated, response.StatusCode())
+	shortenedURL := string(response.Body())
+	endpointURL := srv.URL + "/api/urls/" + shortenedURL[strings.LastIndex(shortenedURL, "/")+1:]
+
+	// Long URLs make every update record large, well past the 64 KiB a
+	// line scanner reads, once the history is full.
+	padding := strings.Repeat("a", 2000)
+	const edits = entity.MaxHistory + 10
+	for i := 1; i <= edits; i++ {
+		response, err := owner.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(fmt.Sprintf(`{"url":"https://example.com/%d?p=%s"}`, i, padding)).
+			Patch(endpointURL)
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusOK, response.StatusCode(), string(response.Body()))
+	}
+
+	require.NoError(t, storage.InitFileStorage(), "storage should load its own file")
+
+	response, err = owner.R().Get(endpointURL + "/history")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+
+	var history []models.URLChange
+	require.NoError(t, json.Unmarshal(response.Body(), &history))
+	require.Len(t, history, entity.MaxHistory, "history should be capped")
+	assert.Equal(t, fmt.Sprintf("https://example.com/%d?p=%s", edits, padding), history[len(history)-1].NewURL)
+	assert.Equal(t, fmt.Sprintf("https://example.com/%d?p=%s", edits-entity.MaxHistory, padding), history[0].OldURL)
+}
+
+func TestDeleteURLRacesUpdate(t *testing.T) {
+	srv := newTestServer(t)
+	owner := resty.New()
+
+	for i := range 50 {
+		response, err := owner.R().SetBody(fmt.Sprintf("https://example.com/race/%d", i)).Post(srv.URL)
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusCreated, response.StatusCode())
+		shortenedURL := string(response.Body())
+		id := shortenedURL[strings.LastIndex(shortenedURL, "/")+1:]
+		endpointURL := srv.URL + "/api/urls/" + id
+		ifMatch := `"` + id + `-1"`
+
+		var wg sync.WaitGroup
+		var patched, deleted *resty.Response
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			patched, _ = owner.R().
+				SetHeader("Content-Type", "application/json").
+				SetHeader("If-Match", ifMatch).
+				SetBody(`{"title":"changed"}`).
+				Patch(endpointURL)
+		}()
+		go func() {
+			defer wg.Done()
+			deleted, _ = owner.R().SetHeader("If-Match", ifMatch).Delete(endpointURL)
+		}()
+		wg.Wait()
+		require.NotNil(t, patched)
+		require.NotNil(t, deleted)
+
+		// Whichever comes second must see the version changed or the link
+		// gone, never act on the stale ETag.
+		if deleted.StatusCode() == http.StatusNoContent {
+			assert.Equal(t, http.StatusNotFound, patched.StatusCode(), "update after delete")
+		} else {
+			assert.Equal(t, http.StatusOK, patched.StatusCode())
+			assert.Equal(t, http.StatusPreconditionFailed, deleted.StatusCode(), "delete with stale ETag")
+		}
+	}
+}
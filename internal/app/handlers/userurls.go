@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/models"
+)
+
+var errUnknownSort = errors.New("sort must be one of created, clicks or title, optionally prefixed with -")
+
+// linkOrders are the sort options of ListUserURLs, ascending. A leading
+// "-" in the sort parameter reverses them.
+var linkOrders = map[string]func(a, b entity.ShortenURL) int{
+	"created": func(a, b entity.ShortenURL) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"clicks":  func(a, b entity.ShortenURL) int { return cmp.Compare(a.Clicks, b.Clicks) },
+	"title": func(a, b entity.ShortenURL) int {
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	},
+}
+
+const defaultLinkOrder = "-created"
+
+// ListUserURLs lists the personal links of the user, or with the workspace
+// parameter the links of a workspace the user is a member of. Repeated tag
+// parameters keep links having all of the tags, q keeps links whose title
+// or original URL contains it, ignoring case, and sort orders the result.
+func ListUserURLs(response http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+
+	sortBy := cmp.Or(query.Get("sort"), defaultLinkOrder)
+	descending := strings.HasPrefix(sortBy, "-")
+	order, ok := linkOrders[strings.TrimPrefix(sortBy, "-")]
+	if !ok {
+		JSONError(response, errUnknownSort.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tags, err := normalizeTags(query["tag"])
+	if err != nil {
+		JSONError(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := auth.UserID(request.Context())
+
+	var shortenURLs []entity.ShortenURL
+	if workspaceID := query.Get("workspace"); workspaceID != "" {
+		workspace, ok := storage.Workspaces.RetrieveWorkspace(workspaceID)
+		if !ok {
+			JSONError(response, entity.ErrWorkspaceNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		if err := checkWorkspaceAccess(workspace, userID, entity.Role.CanView); err != nil {
+			JSONError(response, err.Error(), workspaceErrorStatus(err))
+			return
+		}
+		shortenURLs = storage.Repository.ListByWorkspace(workspaceID, tags...)
+	} else {
+		shortenURLs = storage.Repository.ListByOwner(userID, tags...)
+	}
+
+	if search := strings.ToLower(query.Get("q")); search != "" {
+		shortenURLs = slices.DeleteFunc(shortenURLs, func(e entity.ShortenURL) bool {
+			return !strings.Contains(strings.ToLower(e.Title), search) &&
+				!strings.Contains(strings.ToLower(e.OriginalURL), search)
+		})
+	}
+
+	slices.SortFunc(shortenURLs, func(a, b entity.ShortenURL) int {
+		c := order(a, b)
+		if descending {
+			c = -c
+		}
+		return cmp.Or(c, strings.Compare(a.ID, b.ID))
+	})
+
+	urlResponses := make([]models.URLResponse, 0, len(shortenURLs))
+	for _, shortenURL := range shortenURLs {
+		urlResponses = append(urlResponses, newURLResponse(shortenURL, userID))
+	}
+
+	writeJSON(response, http.StatusOK, "", urlResponses)
+}
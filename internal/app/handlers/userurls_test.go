@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListUserURLs(t *testing.T) {
+	srv := newTestServer(t)
+
+	user := resty.New()
+	for _, requestBody := range []string{
+		`{"url":"https://example.com/a","title":"Spring launch","tags":["launch","ads"]}`,
+		`{"url":"https://example.com/b","title":"Docs","tags":["docs"]}`,
+		`{"url":"https://docs.example.com/c","title":"API reference","tags":["docs","launch"]}`,
+	} {
+		response, err := user.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(requestBody).
+			Post(srv.URL + "/api/shorten")
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusCreated, response.StatusCode())
+	}
+	// Links of other users are never listed.
+	shortenJSON(t, srv, `{"url":"https://example.com/other","tags":["docs"]}`)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedCode   int
+		expectedTitles []string
+	}{
+		{name: "All, newest first", query: "", expectedCode: http.StatusOK, expectedTitles: []string{"API reference", "Docs", "Spring launch"}},
+		{name: "By tag", query: "?tag=docs", expectedCode: http.StatusOK, expectedTitles: []string{"API reference", "Docs"}},
+		{name: "By several tags", query: "?tag=docs&tag=Launch", expectedCode: http.StatusOK, expectedTitles: []string{"API reference"}},
+		{name: "Unknown tag", query: "?tag=missing", expectedCode: http.StatusOK, expectedTitles: []string{}},
+		{name: "Search title", query: "?q=LAUNCH", expectedCode: http.StatusOK, expectedTitles: []string{"Spring launch"}},
+		{name: "Search destination", query: "?q=docs.example", expectedCode: http.StatusOK, expectedTitles: []string{"API reference"}},
+		{name: "Sort by title", query: "?sort=title", expectedCode: http.StatusOK, expectedTitles: []string{"API reference", "Docs", "Spring launch"}},
+		{name: "Sort by title descending", query: "?sort=-title", expectedCode: http.StatusOK, expectedTitles: []string{"Spring launch", "Docs", "API reference"}},
+		{name: "Unknown sort", query: "?sort=owner", expectedCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := user.R().Get(srv.URL + "/api/user/urls" + tt.query)
+			require.NoError(t, err, "error making HTTP request")
+			require.Equal(t, tt.expectedCode, response.StatusCode())
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var urlResponses []models.URLResponse
+			require.NoError(t, json.Unmarshal(response.Body(), &urlResponses))
+			titles := []string{}
+			for _, urlResponse := range urlResponses {
+				titles = append(titles, urlResponse.Title)
+			}
+			assert.Equal(t, tt.expectedTitles, titles)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/models"
+)
+
+const utmCampaignParam = "utm_campaign"
+
+// applyUTM sets the utm_* parameters given in utm on the URL. Parameters
+// already present are replaced, the rest of the query is left untouched.
+func applyUTM(originalURL string, utm *models.UTMParams) (string, error) {
+	params := []struct{ key, value string }{
+		{"utm_source", utm.Source},
+		{"utm_medium", utm.Medium},
+		{utmCampaignParam, utm.Campaign},
+		{"utm_term", utm.Term},
+		{"utm_content", utm.Content},
+	}
+
+	var utmQuery []string
+	for _, param := range params {
+		if param.value != "" {
+			utmQuery = append(utmQuery, param.key+"="+url.QueryEscape(param.value))
+		}
+	}
+	if len(utmQuery) == 0 {
+		return originalURL, nil
+	}
+
+	parsedURL, err := url.Parse(originalURL)
+	if err != nil {
+		return "", err
+	}
+	parsedURL.RawQuery = mergeQuery(parsedURL.RawQuery, strings.Join(utmQuery, "&"), config.QueryConflictRequest)
+
+	return parsedURL.String(), nil
+}
+
+// campaign returns the utm_campaign the URL is tagged with, if any.
+func campaign(originalURL string) string {
+	parsedURL, err := url.Parse(originalURL)
+	if err != nil {
+		return ""
+	}
+	return parsedURL.Query().Get(utmCampaignParam)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/app/cidr"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortenURLJSONWithUTM(t *testing.T) {
+	srv := newTestServer(t)
+
+	response, err := resty.New().R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`{"url":"https://example.com/sale?b=1&utm_source=old&a=2","utm":{"source":"newsletter","medium":"email","campaign":"spring sale"}}`).
+		Post(srv.URL + "/api/shorten")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusCreated, response.StatusCode())
+
+	var shortenResponse models.ShortenResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &shortenResponse))
+	assert.Equal(t, "https://example.com/sale?b=1&a=2&utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale", shortenResponse.Destination)
+
+	response, err = noRedirectClient().R().Get(shortenResponse.Result)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, shortenResponse.Destination, response.Header().Get("Location"))
+}
+
+func TestGetLinkStats(t *testing.T) {
+	srv := newTestServer(t)
+
+	trustedSubnet, err := cidr.Parse("127.0.0.0/8,::1")
+	require.NoError(t, err)
+	config.TrustedSubnet = trustedSubnet
+	defer func() { config.TrustedSubnet = nil }()
+
+	springURL := shortenJSON(t, srv, `{"url":"https://example.com/a","utm":{"campaign":"spring"}}`)
+	shortenJSON(t, srv, `{"url":"https://example.com/b","utm":{"campaign":"autumn"}}`)
+	shortenJSON(t, srv, `{"url":"https://example.com/c"}`)
+
+	_, err = noRedirectClient().R().Get(springURL)
+	require.NoError(t, err, "error making HTTP request")
+
+	tests := []struct {
+		name              string
+		query             string
+		expectedCampaigns []string
+	}{
+		{name: "All links", query: "", expectedCampaigns: []string{"spring", "autumn", ""}},
+		{name: "Filtered by campaign", query: "?campaign=spring", expectedCampaigns: []string{"spring"}},
+		{name: "Unknown campaign", query: "?campaign=winter", expectedCampaigns: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := resty.New().R().Get(srv.URL + "/api/admin/stats/links" + tt.query)
+			require.NoError(t, err, "error making HTTP request")
+			require.Equal(t, http.StatusOK, response.StatusCode())
+
+			var linkStats []models.LinkStats
+			require.NoError(t, json.Unmarshal(response.Body(), &linkStats))
+
+			actualCampaigns := []string{}
+			for _, stats := range linkStats {
+				actualCampaigns = append(actualCampaigns, stats.Campaign)
+			}
+			if tt.query == "" {
+				// Only the first position is fixed by the click count.
+				require.Len(t, actualCampaigns, len(tt.expectedCampaigns))
+				assert.Equal(t, "spring", actualCampaigns[0])
+				assert.Equal(t, int64(1), linkStats[0].Clicks)
+				assert.ElementsMatch(t, tt.expectedCampaigns, actualCampaigns)
+				return
+			}
+			assert.Equal(t, tt.expectedCampaigns, actualCampaigns)
+		})
+	}
+}
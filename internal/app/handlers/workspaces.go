@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/randstr"
+	"github.com/leodayo/url-shortener/internal/app/storage"
+	"github.com/leodayo/url-shortener/internal/logger"
+	"github.com/leodayo/url-shortener/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	workspaceIDLength      = 8
+	maxWorkspaceNameLength = 128
+)
+
+var (
+	errWorkspaceForbidden   = errors.New("not allowed to do this in the workspace")
+	errEmptyWorkspaceName   = errors.New("name must not be empty")
+	errWorkspaceNameTooLong = fmt.Errorf("name must not exceed %d characters", maxWorkspaceNameLength)
+	errUnknownRole          = errors.New("role must be one of owner, editor or viewer")
+	errLastOwner            = errors.New("workspace must keep at least one owner")
+)
+
+// CreateWorkspace creates a workspace owned by the user.
+func CreateWorkspace(response http.ResponseWriter, request *http.Request) {
+	name, ok := decodeWorkspaceName(response, request)
+	if !ok {
+		return
+	}
+
+	id, err := randstr.RandString(workspaceIDLength)
+	if err != nil {
+		JSONError(response, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	userID := auth.UserID(request.Context())
+	if !storage.Workspaces.StoreWorkspace(entity.Workspace{
+		ID:      id,
+		Name:    name,
+		Members: []entity.Member{{UserID: userID, Role: entity.RoleOwner}},
+	}) {
+		JSONError(response, errStoreFailed.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	workspace, _ := storage.Workspaces.RetrieveWorkspace(id)
+	writeJSON(response, http.StatusCreated, "", newWorkspaceResponse(workspace, userID))
+}
+
+// ListWorkspaces lists the workspaces the user is a member of, by name.
+func ListWorkspaces(response http.ResponseWriter, request *http.Request) {
+	userID := auth.UserID(request.Context())
+
+	workspaces := storage.Workspaces.ListWorkspaces(userID)
+	slices.SortFunc(workspaces, func(a, b entity.Workspace) int {
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	workspaceResponses := make([]models.WorkspaceResponse, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		workspaceResponses = append(workspaceResponses, newWorkspaceResponse(workspace, userID))
+	}
+	writeJSON(response, http.StatusOK, "", workspaceResponses)
+}
+
+func GetWorkspace(response http.ResponseWriter, request *http.Request) {
+	userID := auth.UserID(request.Context())
+
+	workspace, ok := storage.Workspaces.RetrieveWorkspace(request.PathValue("id"))
+	if !ok {
+		JSONError(response, entity.ErrWorkspaceNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err := checkWorkspaceAccess(workspace, userID, entity.Role.CanView); err != nil {
+		JSONError(response, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	writeJSON(response, http.StatusOK, "", newWorkspaceResponse(workspace, userID))
+}
+
+// RenameWorkspace changes the name of a workspace, which only its owners
+// may do.
+func RenameWorkspace(response http.ResponseWriter, request *http.Request) {
+	name, ok := decodeWorkspaceName(response, request)
+	if !ok {
+		return
+	}
+
+	userID := auth.UserID(request.Context())
+	workspace, err := storage.Workspaces.UpdateWorkspace(request.PathValue("id"), func(w entity.Workspace) (entity.Workspace, error) {
+		if err := checkWorkspaceAccess(w, userID, entity.Role.CanManage); err != nil {
+			return w, err
+		}
+		w.Name = name
+		return w, nil
+	})
+	if err != nil {
+		JSONError(response, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	writeJSON(response, http.StatusOK, "", newWorkspaceResponse(workspace, userID))
+}
+
+// DeleteWorkspace deletes a workspace without links, which only its owners
+// may do. The storage checks that it has no links.
+func DeleteWorkspace(response http.ResponseWriter, request *http.Request) {
+	workspace, ok := storage.Workspaces.RetrieveWorkspace(request.PathValue("id"))
+	if !ok {
+		JSONError(response, entity.ErrWorkspaceNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err := checkWorkspaceAccess(workspace, auth.UserID(request.Context()), entity.Role.CanManage); err != nil {
+		JSONError(response, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+	if err := storage.Workspaces.DeleteWorkspace(workspace.ID); err != nil {
+		JSONError(response, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// PutMember adds a user to a workspace or changes their role, which only
+// the workspace owners may do.
+func PutMember(response http.ResponseWriter, request *http.Request) {
+	if request.Header.Get("Content-Type") != "application/json" {
+		JSONError(response, "Content-Type not supported", http.StatusBadRequest)
+		return
+	}
+
+	var memberRequest models.MemberRequest
+	if err := decodeJSON(http.MaxBytesReader(response, request.Body, config.MaxBodySize), &memberRequest); err != nil {
+		logger.Log.Debug("cannot decode request JSON body", zap.Error(err))
+		JSONError(response, bodyErrorMessage(err), bodyErrorStatus(err))
+		return
+	}
+
+	role := entity.Role(memberRequest.Role)
+	if !role.Valid() {
+		JSONError(response, errUnknownRole.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := auth.UserID(request.Context())
+	memberID := request.PathValue("userID")
+	workspace, err := storage.Workspaces.UpdateWorkspace(request.PathValue("id"), func(w entity.Workspace) (entity.Workspace, error) {
+		if err := checkWorkspaceAccess(w, userID, entity.Role.CanManage); err != nil {
+			return w, err
+		}
+
+		members := slices.Clone(w.Members)
+		i := slices.IndexFunc(members, func(m entity.Member) bool { return m.UserID == memberID })
+		if i < 0 {
+			members = append(members, entity.Member{UserID: memberID, Role: role})
+		} else {
+			members[i].Role = role
+		}
+
+		if !hasOwner(members) {
+			return w, errLastOwner
+		}
+		w.Members = members
+		return w, nil
+	})
+	if err != nil {
+		JSONError(response, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	writeJSON(response, http.StatusOK, "", newWorkspaceResponse(workspace, userID))
+}
+
+// DeleteMember removes a user from a workspace. Owners may remove anyone,
+// other members only themselves.
+func DeleteMember(response http.ResponseWriter, request *http.Request) {
+	userID := auth.UserID(request.Context())
+	memberID := request.PathValue("userID")
+
+	_, err := storage.Workspaces.UpdateWorkspace(request.PathValue("id"), func(w entity.Workspace) (entity.Workspace, error) {
+		allowed := entity.Role.CanManage
+		if memberID == userID {
+			allowed = entity.Role.CanView
+		}
+		if err := checkWorkspaceAccess(w, userID, allowed); err != nil {
+			return w, err
+		}
+
+		members := slices.DeleteFunc(slices.Clone(w.Members), func(m entity.Member) bool { return m.UserID == memberID })
+		if !hasOwner(members) {
+			return w, errLastOwner
+		}
+		w.Members = members
+		return w, nil
+	})
+	if err != nil {
+		JSONError(response, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func decodeWorkspaceName(response http.ResponseWriter, request *http.Request) (string, bool) {
+	if request.Header.Get("Content-Type") != "application/json" {
+		JSONError(response, "Content-Type not supported", http.StatusBadRequest)
+		return "", false
+	}
+
+	var workspaceRequest models.WorkspaceRequest
+	if err := decodeJSON(http.MaxBytesReader(response, request.Body, config.MaxBodySize), &workspaceRequest); err != nil {
+		logger.Log.Debug("cannot decode request JSON body", zap.Error(err))
+		JSONError(response, bodyErrorMessage(err), bodyErrorStatus(err))
+		return "", false
+	}
+
+	name := strings.TrimSpace(workspaceRequest.Name)
+	if name == "" {
+		JSONError(response, errEmptyWorkspaceName.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(name) > maxWorkspaceNameLength {
+		JSONError(response, errWorkspaceNameTooLong.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
+
+// checkWorkspaceAccess checks the role of the user in the workspace with
+// allowed. The workspace is not found for users who are not members.
+func checkWorkspaceAccess(workspace entity.Workspace, userID string, allowed func(entity.Role) bool) error {
+	role := workspace.Role(userID)
+	if role == "" {
+		return entity.ErrWorkspaceNotFound
+	}
+	if !allowed(role) {
+		return errWorkspaceForbidden
+	}
+	return nil
+}
+
+// workspaceRoles returns the roles of the user by workspace ID.
+func workspaceRoles(userID string) map[string]entity.Role {
+	roles := make(map[string]entity.Role)
+	for _, workspace := range storage.Workspaces.ListWorkspaces(userID) {
+		roles[workspace.ID] = workspace.Role(userID)
+	}
+	return roles
+}
+
+func hasOwner(members []entity.Member) bool {
+	return slices.ContainsFunc(members, func(m entity.Member) bool { return m.Role == entity.RoleOwner })
+}
+
+func workspaceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, entity.ErrWorkspaceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errWorkspaceForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errLastOwner), errors.Is(err, entity.ErrWorkspaceNotEmpty):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func newWorkspaceResponse(workspace entity.Workspace, userID string) models.WorkspaceResponse {
+	workspaceResponse := models.WorkspaceResponse{
+		ID:        workspace.ID,
+		Name:      workspace.Name,
+		CreatedAt: workspace.CreatedAt,
+		Role:      string(workspace.Role(userID)),
+		Members:   make([]models.Member, 0, len(workspace.Members)),
+	}
+	for _, member := range workspace.Members {
+		workspaceResponse.Members = append(workspaceResponse.Members, models.Member{
+			UserID: member.UserID,
+			Role:   string(member.Role),
+		})
+	}
+	return workspaceResponse
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leodayo/url-shortener/internal/app/auth"
+	"github.com/leodayo/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUser returns a client holding a fresh auth cookie and its user ID.
+func newUser(t *testing.T, srv *httptest.Server) (*resty.Client, string) {
+	client := resty.New()
+	_, err := client.R().Get(srv.URL + "/api/workspaces")
+	require.NoError(t, err, "error making HTTP request")
+
+	serverURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	for _, cookie := range client.GetClient().Jar.Cookies(serverURL) {
+		if cookie.Name == auth.CookieName {
+			userID, _, _ := strings.Cut(cookie.Value, ".")
+			return client, userID
+		}
+	}
+	require.FailNow(t, "no auth cookie issued")
+	return nil, ""
+}
+
+func TestWorkspaces(t *testing.T) {
+	srv := newTestServer(t)
+
+	owner, _ := newUser(t, srv)
+	editor, editorID := newUser(t, srv)
+	viewer, viewerID := newUser(t, srv)
+	outsider, _ := newUser(t, srv)
+
+	response, err := owner.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`{"name":"Marketing"}`).
+		Post(srv.URL + "/api/workspaces")
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusCreated, response.StatusCode())
+
+	var workspace models.WorkspaceResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &workspace))
+	assert.Equal(t, "owner", workspace.Role)
+	workspaceURL := srv.URL + "/api/workspaces/" + workspace.ID
+
+	for userID, role := range map[string]string{editorID: "editor", viewerID: "viewer"} {
+		response, err = owner.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(`{"role":"` + role + `"}`).
+			Put(workspaceURL + "/members/" + userID)
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusOK, response.StatusCode(), string(response.Body()))
+	}
+
+	shorten := func(client *resty.Client) *resty.Response {
+		response, err := client.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(`{"url":"https://example.com/campaign","workspace_id":"` + workspace.ID + `"}`).
+			Post(srv.URL + "/api/shorten")
+		require.NoError(t, err, "error making HTTP request")
+		return response
+	}
+
+	assert.Equal(t, http.StatusForbidden, shorten(viewer).StatusCode(), "viewers cannot create links")
+	assert.Equal(t, http.StatusNotFound, shorten(outsider).StatusCode(), "workspace is hidden from non-members")
+	response = shorten(editor)
+	require.Equal(t, http.StatusCreated, response.StatusCode())
+
+	var shortenResponse models.ShortenResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &shortenResponse))
+	linkURL := srv.URL + "/api/urls/" + shortenResponse.Result[strings.LastIndex(shortenResponse.Result, "/")+1:]
+
+	response, err = viewer.R().Get(srv.URL + "/api/user/urls?workspace=" + workspace.ID)
+	require.NoError(t, err, "error making HTTP request")
+	require.Equal(t, http.StatusOK, response.StatusCode())
+	var urlResponses []models.URLResponse
+	require.NoError(t, json.Unmarshal(response.Body(), &urlResponses))
+	require.Len(t, urlResponses, 1)
+	assert.False(t, urlResponses[0].IsOwner)
+	assert.NotContains(t, string(response.Body()), editorID, "the creator's user ID should not be shown")
+
+	tests := []struct {
+		name         string
+		client       *resty.Client
+		expectedCode int
+	}{
+		{name: "Viewer", client: viewer, expectedCode: http.StatusForbidden},
+		{name: "Outsider", client: outsider, expectedCode: http.StatusForbidden},
+		{name: "Owner", client: owner, expectedCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run("Update by "+tt.name, func(t *testing.T) {
+			response, err := tt.client.R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(`{"title":"Campaign"}`).
+				Patch(linkURL)
+			require.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, tt.expectedCode, response.StatusCode())
+		})
+	}
+
+	response, err = owner.R().Delete(workspaceURL)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusConflict, response.StatusCode(), "workspace with links cannot be deleted")
+
+	response, err = viewer.R().Delete(workspaceURL + "/members/" + viewerID)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode(), "members may leave")
+
+	response, err = viewer.R().Get(srv.URL + "/api/user/urls?workspace=" + workspace.ID)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode())
+
+	response, err = owner.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`{"role":"viewer"}`).
+		Put(workspaceURL + "/members/" + workspace.Members[0].UserID)
+	require.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusConflict, response.StatusCode(), "the last owner cannot be demoted")
+}
+
+func TestDeleteWorkspaceRacesShorten(t *testing.T) {
+	srv := newTestServer(t)
+	owner, _ := newUser(t, srv)
+
+	for i := range 50 {
+		response, err := owner.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(`{"name":"Race"}`).
+			Post(srv.URL + "/api/workspaces")
+		require.NoError(t, err, "error making HTTP request")
+		require.Equal(t, http.StatusCreated, response.StatusCode())
+		var workspace models.WorkspaceResponse
+		require.NoError(t, json.Unmarshal(response.Body(), &workspace))
+
+		var wg sync.WaitGroup
+		var shortened, deleted *resty.Response
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			shortened, _ = owner.R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(fmt.Sprintf(`{"url":"https://example.com/race/%d","workspace_id":%q}`, i, workspace.ID)).
+				Post(srv.URL + "/api/shorten")
+		}()
+		go func() {
+			defer wg.Done()
+			deleted, _ = owner.R().Delete(srv.URL + "/api/workspaces/" + workspace.ID)
+		}()
+		wg.Wait()
+		require.NotNil(t, shortened)
+		require.NotNil(t, deleted)
+
+		// Either the link made it in and the workspace stays, or the
+		// workspace is gone and so is the link.
+		if deleted.StatusCode() == http.StatusNoContent {
+			assert.Equal(t, http.StatusNotFound, shortened.StatusCode(), "link created in a deleted workspace")
+		} else {
+			assert.Equal(t, http.StatusCreated, shortened.StatusCode())
+			assert.Equal(t, http.StatusConflict, deleted.StatusCode())
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const minSecretLength = 32
+
+// LoadDir reads the keys in dir, named by their kid and an extension
+// telling their type:
+//
+//	<kid>.hs256  HS256 secret of at least 32 bytes
+//	<kid>.pem    Ed25519 key, PEM encoded as a PKCS #8 private key, or as a
+//	             PKIX public key to only verify tokens
+//
+// Other files are ignored.
+func LoadDir(dir string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []Key
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if ext != ".hs256" && ext != ".pem" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parseKey(strings.TrimSuffix(name, ext), ext, data)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", name, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(keys...)
+}
+
+func parseKey(id, ext string, data []byte) (Key, error) {
+	if ext == ".hs256" {
+		secret := bytes.TrimSpace(data)
+		if len(secret) < minSecretLength {
+			return Key{}, fmt.Errorf("secret shorter than %d bytes", minSecretLength)
+		}
+		return NewHS256Key(id, secret), nil
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM block")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		privateKey, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return Key{}, errors.New("not an Ed25519 key")
+		}
+		return NewEd25519Key(id, privateKey), nil
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		publicKey, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return Key{}, errors.New("not an Ed25519 key")
+		}
+		return NewEd25519PublicKey(id, publicKey), nil
+	}
+	return Key{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
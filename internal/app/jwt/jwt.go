@@ -0,0 +1,219 @@
+// Package jwt signs and verifies JSON Web Tokens with HS256 or Ed25519
+// keys identified by their kid, and publishes the public keys as a JWKS.
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+	ErrNoSigningKey = errors.New("no signing key")
+)
+
+// Claims are the registered claims used by the service, plus the scope the
+// token grants.
+type Claims struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub"`
+	Scope     string `json:"scope,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid"`
+}
+
+// Key is a signing or verification key. Ed25519 keys without a private
+// part only verify tokens.
+type Key struct {
+	ID        string
+	Algorithm string
+
+	secret     []byte
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+func NewHS256Key(id string, secret []byte) Key {
+	return Key{ID: id, Algorithm: AlgHS256, secret: secret}
+}
+
+func NewEd25519Key(id string, privateKey ed25519.PrivateKey) Key {
+	return Key{ID: id, Algorithm: AlgEdDSA, privateKey: privateKey, publicKey: privateKey.Public().(ed25519.PublicKey)}
+}
+
+func NewEd25519PublicKey(id string, publicKey ed25519.PublicKey) Key {
+	return Key{ID: id, Algorithm: AlgEdDSA, publicKey: publicKey}
+}
+
+func (k Key) canSign() bool {
+	return k.secret != nil || k.privateKey != nil
+}
+
+func (k Key) sign(data []byte) []byte {
+	if k.Algorithm == AlgHS256 {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(data)
+		return mac.Sum(nil)
+	}
+	return ed25519.Sign(k.privateKey, data)
+}
+
+func (k Key) verify(data, signature []byte) bool {
+	if k.Algorithm == AlgHS256 {
+		return hmac.Equal(k.sign(data), signature)
+	}
+	return ed25519.Verify(k.publicKey, data, signature)
+}
+
+// KeySet holds the keys accepted for verification. All of them stay
+// active, so tokens signed with an older key verify until they expire.
+// New tokens are signed with the signing key whose kid sorts last, which
+// makes rotation a matter of adding a key with a later kid.
+type KeySet struct {
+	keys    map[string]Key
+	signing Key
+}
+
+func NewKeySet(keys ...Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]Key, len(keys))}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("key without kid")
+		}
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate kid %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+
+		if key.canSign() && key.ID > ks.signing.ID {
+			ks.signing = key
+		}
+	}
+	return ks, nil
+}
+
+// Sign returns a token with the claims, signed with the current signing
+// key.
+func (ks *KeySet) Sign(claims Claims) (string, error) {
+	if !ks.signing.canSign() {
+		return "", ErrNoSigningKey
+	}
+
+	headerJSON, err := json.Marshal(header{Algorithm: ks.signing.Algorithm, Type: "JWT", KeyID: ks.signing.ID})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encode(headerJSON) + "." + encode(claimsJSON)
+	return signingInput + "." + encode(ks.signing.sign([]byte(signingInput))), nil
+}
+
+// Verify checks the signature and lifetime of the token and returns its
+// claims. The algorithm must be the one of the key named by kid, a token
+// cannot pick another.
+func (ks *KeySet) Verify(token string, now time.Time) (Claims, error) {
+	var claims Claims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrInvalidToken
+	}
+
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return claims, ErrInvalidToken
+	}
+	key, ok := ks.keys[h.KeyID]
+	if !ok || key.Algorithm != h.Algorithm {
+		return claims, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return claims, ErrInvalidToken
+	}
+
+	if err := decodeJSON(parts[1], &claims); err != nil || claims.Subject == "" || claims.ExpiresAt == 0 {
+		return Claims{}, ErrInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrExpiredToken
+	}
+	if claims.NotBefore != 0 && now.Unix() < claims.NotBefore {
+		return Claims{}, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	X         string `json:"x"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys, sorted by kid. HS256 secrets are never
+// published, such tokens can only be verified by holders of the secret.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		if key.Algorithm != AlgEdDSA {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			KeyID:     key.ID,
+			Algorithm: AlgEdDSA,
+			Use:       "sig",
+			X:         encode(key.publicKey),
+		})
+	}
+
+	slices.SortFunc(jwks.Keys, func(a, b JWK) int { return strings.Compare(a.KeyID, b.KeyID) })
+	return jwks
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeJSON(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	now := time.Unix(1_700_000_000, 0)
+
+	hsKey := NewHS256Key("2024-01", []byte(strings.Repeat("s", 32)))
+	edKey := NewEd25519Key("2024-02", privateKey)
+	ks, err := NewKeySet(hsKey, edKey)
+	require.NoError(t, err)
+	oldKeys, err := NewKeySet(hsKey)
+	require.NoError(t, err)
+
+	claims := Claims{Subject: "user", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}
+	token, err := ks.Sign(claims)
+	require.NoError(t, err)
+	assert.Contains(t, decodePart(t, token, 0), `"kid":"2024-02"`, "the latest kid signs")
+	oldToken, err := oldKeys.Sign(claims)
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + encode([]byte(`{"sub":"admin","exp":9999999999}`)) + "." + parts[2]
+	// An HS256 token using the public key of the EdDSA key as its secret.
+	confused, err := (&KeySet{signing: Key{ID: "2024-02", Algorithm: AlgHS256, secret: edKey.publicKey}}).Sign(claims)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		token         string
+		now           time.Time
+		expectedError error
+	}{
+		{name: "Valid", token: token, now: now},
+		{name: "Signed with an older key", token: oldToken, now: now},
+		{name: "Expired", token: token, now: now.Add(time.Hour), expectedError: ErrExpiredToken},
+		{name: "Tampered claims", token: tampered, now: now, expectedError: ErrInvalidToken},
+		{name: "Algorithm not matching the key", token: confused, now: now, expectedError: ErrInvalidToken},
+		{name: "Malformed", token: "not.a.token", now: now, expectedError: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verified, err := ks.Verify(tt.token, tt.now)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, claims, verified)
+		})
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	oldPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(oldPublicKey)
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		"a-secret.hs256": []byte(strings.Repeat("s", 32) + "\n"),
+		"b-old.pem":      pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+		"c-current.pem":  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+		"README":         []byte("ignored"),
+	}
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0600))
+	}
+
+	ks, err := LoadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "c-current", ks.signing.ID)
+
+	jwks := ks.JWKS()
+	require.Len(t, jwks.Keys, 2, "only Ed25519 keys are published")
+	assert.Equal(t, "b-old", jwks.Keys[0].KeyID)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(publicKey), jwks.Keys[1].X)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d-short.hs256"), []byte("short"), 0600))
+	_, err = LoadDir(dir)
+	assert.Error(t, err)
+}
+
+func decodePart(t *testing.T, token string, i int) string {
+	data, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[i])
+	require.NoError(t, err)
+	return string(data)
+}
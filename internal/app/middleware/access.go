@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/leodayo/url-shortener/internal/app/cidr"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// DeniedNetworks are blocked from every route.
+	DeniedNetworks cidr.Set
+	// TrustedNetworks extend config.TrustedSubnet for admin routes.
+	TrustedNetworks cidr.Set
+)
+
+// Denylist rejects requests whose client IP is in DeniedNetworks.
+func Denylist(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := ClientIP(r); DeniedNetworks.Contains(ip) {
+			logger.Log.Info("request from denied network rejected", zap.String("ip", ip.String()))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// TrustedSubnet only lets through clients from config.TrustedSubnet or
+// TrustedNetworks. With both lists empty every request is rejected.
+func TrustedSubnet(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIP(r)
+		if !config.TrustedSubnet.Contains(ip) && !TrustedNetworks.Contains(ip) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leodayo/url-shortener/internal/app/cidr"
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLists(t *testing.T) {
+	denied, err := cidr.ParseFile([]byte("# abusive ranges\n198.51.100.0/24\n203.0.113.5 # single host\n"))
+	require.NoError(t, err)
+	DeniedNetworks.Store(denied)
+	defer DeniedNetworks.Store(nil)
+
+	trustedSubnet, err := cidr.Parse("10.0.0.0/8")
+	require.NoError(t, err)
+	config.TrustedSubnet = trustedSubnet
+	defer func() { config.TrustedSubnet = nil }()
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		remoteAddr   string
+		expectedCode int
+	}{
+		{name: "Denylist allows unlisted client", handler: Denylist(ok), remoteAddr: "192.0.2.1:1234", expectedCode: http.StatusOK},
+		{name: "Denylist blocks listed range", handler: Denylist(ok), remoteAddr: "198.51.100.77:1234", expectedCode: http.StatusForbidden},
+		{name: "Denylist blocks listed host", handler: Denylist(ok), remoteAddr: "203.0.113.5:1234", expectedCode: http.StatusForbidden},
+		{name: "Trusted subnet allows member", handler: TrustedSubnet(ok), remoteAddr: "10.20.30.40:1234", expectedCode: http.StatusOK},
+		{name: "Trusted subnet rejects outsider", handler: TrustedSubnet(ok), remoteAddr: "192.0.2.1:1234", expectedCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = tt.remoteAddr
+			recorder := httptest.NewRecorder()
+
+			tt.handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tt.expectedCode, recorder.Code)
+		})
+	}
+}
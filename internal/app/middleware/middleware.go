@@ -5,7 +5,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/compression"
+	"github.com/leodayo/url-shortener/internal/compression/brotli"
 	"github.com/leodayo/url-shortener/internal/compression/gzip"
+	"github.com/leodayo/url-shortener/internal/compression/zstd"
 	"github.com/leodayo/url-shortener/internal/logger"
 	"go.uber.org/zap"
 )
@@ -43,6 +47,7 @@ func RequestLogger(h http.Handler) http.Handler {
 		logger.Log.Info("Got incoming HTTP request",
 			zap.String("method", r.Method),
 			zap.String("URI", r.RequestURI),
+			zap.String("ip", ClientIP(r).String()),
 			zap.Duration("took", timeTaken),
 		)
 	})
@@ -63,34 +68,62 @@ func ResponseLogger(h http.Handler) http.Handler {
 	})
 }
 
-// Increases Content-Length for small responses
-// [i.e. for /api/shorten which on success returns ~49 bytes without compression tends to return ~73 bytes with gzip applied]
-// TODO:
-// -> consider making 'compressWriter' write into a buffer first so that the response could be analysed after h.ServeHTTP(wrappedWriter, r)
-// -> apply gzip compression only if content-type is either 'application/json' or 'text/html' and if content length is > ~1000-1500 bytes.
-func GzipMiddleware(h http.Handler) http.Handler {
+// codecs are listed in order of server preference.
+var codecs = []compression.Codec{brotli.Codec, zstd.Codec, gzip.Codec}
+
+// CompressionMiddleware compresses responses with the best codec accepted
+// by the client and decodes compressed request bodies. Responses are
+// buffered until config.CompressMinSize bytes are written so that short
+// bodies, redirects and errors go out uncompressed. Decoded bodies are
+// capped at config.MaxDecompressedSize to defuse decompression bombs.
+func CompressionMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		wrappedWriter := w
+		w.Header().Add("Vary", "Accept-Encoding")
 
-		acceptEncoding := r.Header.Get("Accept-Encoding")
-		if strings.Contains(acceptEncoding, "gzip") {
-			cw := gzip.NewCompressWriter(w)
+		if codec := compression.Negotiate(r.Header.Values("Accept-Encoding"), codecs); codec != nil {
+			cw := compression.NewResponseWriter(w, codec, config.CompressMinSize)
 			defer cw.Close()
 
 			wrappedWriter = cw
 		}
 
-		contentEncoding := r.Header.Get("Content-Encoding")
-		if contentEncoding == "gzip" {
-			cr, err := gzip.NewCompressReader(r.Body)
+		contentEncodings := splitList(r.Header.Values("Content-Encoding"))
+		decoded := false
+		for i := len(contentEncodings) - 1; i >= 0; i-- {
+			if strings.EqualFold(contentEncodings[i], "identity") {
+				continue
+			}
+
+			codec := codecByEncoding(contentEncodings[i])
+			if codec == nil {
+				http.Error(w, "Content-Encoding not supported", http.StatusUnsupportedMediaType)
+				return
+			}
+
+			cr, err := codec.NewReader(r.Body)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
 			defer cr.Close()
 			r.Body = cr
+			decoded = true
+		}
+
+		if decoded {
+			r.Body = http.MaxBytesReader(w, r.Body, config.MaxDecompressedSize)
 		}
 
 		h.ServeHTTP(wrappedWriter, r)
 	})
 }
+
+func codecByEncoding(encoding string) compression.Codec {
+	for _, codec := range codecs {
+		if strings.EqualFold(codec.Encoding(), encoding) {
+			return codec
+		}
+	}
+	return nil
+}
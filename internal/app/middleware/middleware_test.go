@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/compression/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	largeJSON := `{"result":"` + strings.Repeat("a", 2*config.CompressMinSize) + `"}`
+	smallJSON := `{"result":"http://localhost:8080/expand/abcdef"}`
+
+	jsonHandler := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, body)
+		})
+	}
+	redirectHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com/"+strings.Repeat("a", 2*config.CompressMinSize), http.StatusTemporaryRedirect)
+	})
+	binaryHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(strings.Repeat("a", 2*config.CompressMinSize)))
+	})
+
+	tests := []struct {
+		name             string
+		handler          http.Handler
+		acceptEncoding   string
+		expectedCode     int
+		expectedEncoding string
+		expectedBody     string
+	}{
+		{name: "Large JSON is compressed", handler: jsonHandler(largeJSON), acceptEncoding: "gzip", expectedCode: http.StatusCreated, expectedEncoding: "gzip", expectedBody: largeJSON},
+		{name: "Small JSON is not compressed", handler: jsonHandler(smallJSON), acceptEncoding: "gzip", expectedCode: http.StatusCreated, expectedBody: smallJSON},
+		{name: "Client without compression", handler: jsonHandler(largeJSON), acceptEncoding: "", expectedCode: http.StatusCreated, expectedBody: largeJSON},
+		{name: "gzip refused with q=0", handler: jsonHandler(largeJSON), acceptEncoding: "gzip;q=0", expectedCode: http.StatusCreated, expectedBody: largeJSON},
+		{name: "Brotli preferred on tie", handler: jsonHandler(largeJSON), acceptEncoding: "gzip, deflate, br, zstd", expectedCode: http.StatusCreated, expectedEncoding: "br", expectedBody: largeJSON},
+		{name: "Highest q-value wins", handler: jsonHandler(largeJSON), acceptEncoding: "br;q=0.5, zstd;q=0.9, gzip;q=0.8", expectedCode: http.StatusCreated, expectedEncoding: "zstd", expectedBody: largeJSON},
+		{name: "Wildcard accepts everything not refused", handler: jsonHandler(largeJSON), acceptEncoding: "br;q=0, zstd;q=0, *;q=0.5", expectedCode: http.StatusCreated, expectedEncoding: "gzip", expectedBody: largeJSON},
+		{name: "Redirect is not compressed", handler: redirectHandler, acceptEncoding: "gzip", expectedCode: http.StatusTemporaryRedirect},
+		{name: "Binary content is not compressed", handler: binaryHandler, acceptEncoding: "gzip", expectedCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				request.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			recorder := httptest.NewRecorder()
+
+			CompressionMiddleware(tt.handler).ServeHTTP(recorder, request)
+
+			assert.Equal(t, tt.expectedCode, recorder.Code)
+			assert.Equal(t, "Accept-Encoding", recorder.Header().Get("Vary"))
+			require.Equal(t, tt.expectedEncoding, recorder.Header().Get("Content-Encoding"))
+			if tt.expectedEncoding == "" {
+				if tt.expectedBody != "" {
+					assert.Equal(t, tt.expectedBody, recorder.Body.String())
+				}
+				return
+			}
+
+			zr, err := codecByEncoding(tt.expectedEncoding).NewReader(recorder.Body)
+			require.NoError(t, err)
+			defer zr.Close()
+			body, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedBody, string(body))
+		})
+	}
+}
+
+func TestCompressionMiddlewareRequestBody(t *testing.T) {
+	const body = "https://example.com"
+	echoHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	})
+
+	for _, codec := range codecs {
+		t.Run(codec.Encoding(), func(t *testing.T) {
+			var compressed bytes.Buffer
+			zw := codec.NewWriter(&compressed)
+			_, err := io.WriteString(zw, body)
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+
+			request := httptest.NewRequest(http.MethodPost, "/", &compressed)
+			request.Header.Set("Content-Encoding", codec.Encoding())
+			recorder := httptest.NewRecorder()
+
+			CompressionMiddleware(echoHandler).ServeHTTP(recorder, request)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, body, recorder.Body.String())
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		request.Header.Set("Content-Encoding", "compress")
+		recorder := httptest.NewRecorder()
+
+		CompressionMiddleware(echoHandler).ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+	})
+}
+
+func TestCompressionMiddlewareDecompressionBomb(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.Codec.NewWriter(&compressed)
+	_, err := zw.Write(make([]byte, 2*config.MaxDecompressedSize))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	var readErr error
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	})
+
+	request := httptest.NewRequest(http.MethodPost, "/", &compressed)
+	request.Header.Set("Content-Encoding", "gzip")
+	CompressionMiddleware(handler).ServeHTTP(httptest.NewRecorder(), request)
+
+	var maxBytesErr *http.MaxBytesError
+	assert.ErrorAs(t, readErr, &maxBytesErr)
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/leodayo/url-shortener/internal/app/cidr"
+	"github.com/leodayo/url-shortener/internal/app/config"
+)
+
+type clientIPKey struct{}
+
+// RealIP resolves the address of the client that originated the request.
+// Forwarding headers are only honoured when the immediate peer is one of
+// config.TrustedProxies, otherwise any client could spoof its address.
+// The result is available to later handlers through ClientIP.
+func RealIP(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := resolveClientIP(r, config.TrustedProxies)
+		ctx := context.WithValue(r.Context(), clientIPKey{}, ip)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ClientIP returns the address resolved by RealIP, falling back to the
+// immediate peer when the middleware is not installed.
+func ClientIP(r *http.Request) netip.Addr {
+	if ip, ok := r.Context().Value(clientIPKey{}).(netip.Addr); ok {
+		return ip
+	}
+	ip, _ := parseHost(r.RemoteAddr)
+	return ip
+}
+
+func resolveClientIP(r *http.Request, trusted cidr.List) netip.Addr {
+	peer, err := parseHost(r.RemoteAddr)
+	if err != nil || !trusted.Contains(peer) {
+		return peer
+	}
+
+	// A present header settles the address even when its chain ends in an
+	// obfuscated or malformed hop, so that the headers below, which the
+	// client may have set itself, are not consulted in its place.
+	if forwarded := r.Header.Values("Forwarded"); len(forwarded) > 0 {
+		return clientFromChain(parseForwarded(forwarded), trusted, peer)
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		return clientFromChain(splitList(xff), trusted, peer)
+	}
+
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		if ip, err := parseHost(realIP); err == nil {
+			return ip
+		}
+	}
+
+	return peer
+}
+
+// clientFromChain walks a proxy chain from the nearest hop outwards,
+// starting at the trusted peer, and returns the first address that is not
+// a trusted proxy. It stops at the first malformed or obfuscated entry and
+// returns the last address added by a trusted proxy, since nothing before
+// the entry can be trusted.
+func clientFromChain(chain []string, trusted cidr.List, peer netip.Addr) netip.Addr {
+	client := peer
+	for i := len(chain) - 1; i >= 0 && trusted.Contains(client); i-- {
+		ip, err := parseHost(chain[i])
+		if err != nil {
+			break
+		}
+		client = ip
+	}
+
+	return client
+}
+
+// parseForwarded extracts the "for" parameters of RFC 7239 Forwarded headers.
+func parseForwarded(values []string) []string {
+	var chain []string
+	for _, element := range splitList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(name, "for") {
+				chain = append(chain, strings.Trim(value, `"`))
+			}
+		}
+	}
+	return chain
+}
+
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				list = append(list, entry)
+			}
+		}
+	}
+	return list
+}
+
+// parseHost accepts an address with or without a port, IPv6 addresses
+// optionally enclosed in brackets.
+func parseHost(hostport string) (netip.Addr, error) {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return ip.Unmap(), nil
+}
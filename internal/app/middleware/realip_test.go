@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leodayo/url-shortener/internal/app/cidr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted, err := cidr.Parse("10.0.0.0/8, 192.168.0.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expectedIP string
+	}{
+		{name: "Untrusted peer, headers ignored", remoteAddr: "203.0.113.7:5555", headers: map[string]string{"X-Forwarded-For": "1.1.1.1"}, expectedIP: "203.0.113.7"},
+		{name: "Trusted peer without headers", remoteAddr: "10.1.2.3:5555", expectedIP: "10.1.2.3"},
+		{name: "X-Forwarded-For single hop", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"X-Forwarded-For": "198.51.100.1"}, expectedIP: "198.51.100.1"},
+		{name: "X-Forwarded-For skips trusted hops", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"X-Forwarded-For": "198.51.100.1, 198.51.100.2, 192.168.0.1"}, expectedIP: "198.51.100.2"},
+		{name: "X-Forwarded-For all trusted", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"X-Forwarded-For": "10.0.0.5, 10.0.0.6"}, expectedIP: "10.0.0.5"},
+		{name: "X-Forwarded-For garbage", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"X-Forwarded-For": "not an ip"}, expectedIP: "10.1.2.3"},
+		{name: "X-Real-IP", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"X-Real-IP": "198.51.100.9"}, expectedIP: "198.51.100.9"},
+		{name: "Forwarded with IPv6 and port", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.9`}, expectedIP: "2001:db8:cafe::17"},
+		{name: "Forwarded preferred over X-Forwarded-For", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-For": "198.51.100.2"}, expectedIP: "198.51.100.1"},
+		{name: "Forwarded obfuscated stops at peer", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"Forwarded": "for=_hidden", "X-Forwarded-For": "198.51.100.2", "X-Real-IP": "198.51.100.3"}, expectedIP: "10.1.2.3"},
+		{name: "Forwarded obfuscated stops at last trusted hop", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"Forwarded": "for=198.51.100.1, for=_hidden, for=10.0.0.9", "X-Real-IP": "198.51.100.3"}, expectedIP: "10.0.0.9"},
+		{name: "X-Forwarded-For stops at garbage", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"X-Forwarded-For": "198.51.100.1, not an ip, 10.0.0.5", "X-Real-IP": "198.51.100.3"}, expectedIP: "10.0.0.5"},
+		{name: "X-Forwarded-For untrusted hop before garbage", remoteAddr: "10.1.2.3:5555", headers: map[string]string{"X-Forwarded-For": "not an ip, 198.51.100.1"}, expectedIP: "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				request.Header.Set(k, v)
+			}
+
+			actualIP := resolveClientIP(request, trusted)
+			assert.Equal(t, tt.expectedIP, actualIP.String())
+		})
+	}
+}
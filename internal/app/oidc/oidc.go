@@ -0,0 +1,243 @@
+// Package oidc implements the OpenID Connect authorization code flow with
+// PKCE against a single issuer.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// Config describes the client registered with the issuer.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// Provider is an issuer whose endpoints have been discovered.
+type Provider struct {
+	config    Config
+	endpoints discovery
+	client    *http.Client
+	keys      *keyCache
+}
+
+// Discover fetches the configuration of the issuer from its well-known
+// endpoint.
+func Discover(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	var endpoints discovery
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, client, wellKnown, &endpoints); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", config.Issuer, err)
+	}
+	if endpoints.Issuer != config.Issuer {
+		return nil, fmt.Errorf("issuer %q does not match configured %q", endpoints.Issuer, config.Issuer)
+	}
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" || endpoints.JWKSURI == "" {
+		return nil, errors.New("issuer configuration lacks required endpoints")
+	}
+
+	return &Provider{
+		config:    config,
+		endpoints: endpoints,
+		client:    client,
+		keys:      newKeyCache(client, endpoints.JWKSURI),
+	}, nil
+}
+
+// Flow holds the values a login is bound to between the redirect to the
+// issuer and the callback. It must be kept where only the browser that
+// started the login can present it.
+type Flow struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// NewFlow generates the random values of a login.
+func NewFlow() (Flow, error) {
+	var flow Flow
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.CodeVerifier} {
+		var err error
+		if *value, err = randomString(32); err != nil {
+			return Flow{}, err
+		}
+	}
+	return flow, nil
+}
+
+// AuthCodeURL is where the browser is sent to sign in.
+func (p *Provider) AuthCodeURL(flow Flow) string {
+	challenge := sha256.Sum256([]byte(flow.CodeVerifier))
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {flow.State},
+		"nonce":                 {flow.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(p.endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.endpoints.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// EndSessionURL is where the browser is sent to sign out of the issuer,
+// empty if the issuer doesn't support it.
+func (p *Provider) EndSessionURL() string {
+	return p.endpoints.EndSessionEndpoint
+}
+
+// Claims are the ID token claims used by the service.
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	AuthParty string   `json:"azp"`
+	Nonce     string   `json:"nonce"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	Email     string   `json:"email"`
+	Name      string   `json:"name"`
+}
+
+// Exchange redeems the authorization code of the flow and returns the
+// claims of the verified ID token.
+func (p *Provider) Exchange(ctx context.Context, flow Flow, code string) (Claims, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {flow.CodeVerifier},
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer response.Body.Close()
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tokenResponse); err != nil {
+		return Claims{}, fmt.Errorf("decoding token response: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("token endpoint: %s %s", tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+	if tokenResponse.IDToken == "" {
+		return Claims{}, errors.New("token response without id_token")
+	}
+
+	return p.verifyIDToken(ctx, tokenResponse.IDToken, flow.Nonce, time.Now())
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, token, nonce string, now time.Time) (Claims, error) {
+	var claims Claims
+
+	payload, err := p.keys.verify(ctx, token)
+	if err != nil {
+		return claims, err
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, ErrInvalidIDToken
+	}
+
+	switch {
+	case claims.Issuer != p.config.Issuer,
+		!claims.Audience.contains(p.config.ClientID),
+		len(claims.Audience) > 1 && claims.AuthParty != p.config.ClientID,
+		claims.Subject == "",
+		claims.Nonce != nonce,
+		now.Unix() >= claims.ExpiresAt:
+		return Claims{}, ErrInvalidIDToken
+	}
+	return claims, nil
+}
+
+// audience is a single string or an array of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, response.Status)
+	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
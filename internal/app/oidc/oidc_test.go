@@ -0,0 +1,80 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authorize follows the redirect of the issuer and returns the code it
+// issued.
+func authorize(t *testing.T, authCodeURL string, flow Flow) string {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(authCodeURL)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusFound, response.StatusCode)
+
+	location, err := url.Parse(response.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, flow.State, location.Query().Get("state"))
+	return location.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		tamper   func(*Flow)
+		wantErr  bool
+	}{
+		{name: "signed in", lifetime: time.Hour},
+		{name: "wrong nonce", lifetime: time.Hour, tamper: func(f *Flow) { f.Nonce = "other" }, wantErr: true},
+		{name: "wrong verifier", lifetime: time.Hour, tamper: func(f *Flow) { f.CodeVerifier = "other" }, wantErr: true},
+		{name: "expired ID token", lifetime: -time.Minute, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := oidctest.NewIssuer(t, "shortener")
+			issuer.IDTokenLifetime = tt.lifetime
+
+			provider, err := Discover(context.Background(), Config{
+				Issuer:      issuer.URL,
+				ClientID:    issuer.ClientID,
+				RedirectURL: "http://localhost/auth/callback",
+			}, nil)
+			require.NoError(t, err)
+
+			flow, err := NewFlow()
+			require.NoError(t, err)
+			code := authorize(t, provider.AuthCodeURL(flow), flow)
+			if tt.tamper != nil {
+				tt.tamper(&flow)
+			}
+
+			claims, err := provider.Exchange(context.Background(), flow, code)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, issuer.Subject, claims.Subject)
+			assert.Equal(t, issuer.URL, claims.Issuer)
+		})
+	}
+}
+
+func TestDiscoverRejectsOtherIssuer(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, "shortener")
+
+	_, err := Discover(context.Background(), Config{Issuer: issuer.URL + "/", ClientID: issuer.ClientID}, nil)
+	assert.Error(t, err)
+}
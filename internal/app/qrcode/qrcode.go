@@ -0,0 +1,141 @@
+// Package qrcode renders QR codes as PNG or SVG images.
+package qrcode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// Levels are the error correction levels by name, from least to most
+// tolerant of damage.
+var Levels = map[string]qr.Level{
+	"L": qr.L,
+	"M": qr.M,
+	"Q": qr.Q,
+	"H": qr.H,
+}
+
+var ErrInvalidColor = errors.New("color must be a hex RGB value like 000 or 1a2b3c")
+
+// Options control how a code is rendered.
+type Options struct {
+	Level qr.Level
+	// Size is the width of the image in pixels. PNG images are scaled by
+	// whole pixels per module, so they may be narrower, but never below
+	// one pixel per module.
+	Size int
+	// Margin is the width of the quiet zone around the code, in modules.
+	Margin     int
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// code is the encoded text with its quiet zone, in modules.
+type code struct {
+	*qr.Code
+	margin int
+}
+
+func encode(text string, options Options) (code, error) {
+	qrCode, err := qr.Encode(text, options.Level)
+	if err != nil {
+		return code{}, err
+	}
+	return code{Code: qrCode, margin: options.Margin}, nil
+}
+
+func (c code) width() int {
+	return c.Size + 2*c.margin
+}
+
+func (c code) dark(x, y int) bool {
+	return c.Black(x-c.margin, y-c.margin)
+}
+
+// PNG writes the code of text as a PNG image.
+func PNG(w io.Writer, text string, options Options) error {
+	c, err := encode(text, options)
+	if err != nil {
+		return err
+	}
+
+	scale := max(1, options.Size/c.width())
+	img := image.NewPaletted(image.Rect(0, 0, c.width()*scale, c.width()*scale), color.Palette{options.Background, options.Foreground})
+	for y := 0; y < c.width(); y++ {
+		for x := 0; x < c.width(); x++ {
+			if !c.dark(x, y) {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				offset := img.PixOffset(x*scale, y*scale+dy)
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[offset+dx] = 1
+				}
+			}
+		}
+	}
+
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	return encoder.Encode(w, img)
+}
+
+// SVG writes the code of text as an SVG image, drawing dark modules as
+// one path.
+func SVG(w io.Writer, text string, options Options) error {
+	c, err := encode(text, options)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		options.Size, options.Size, c.width(), c.width())
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="%s"/>`, Hex(options.Background))
+	fmt.Fprintf(bw, `<path fill="%s" d="`, Hex(options.Foreground))
+	for y := 0; y < c.width(); y++ {
+		for x := 0; x < c.width(); x++ {
+			// Runs of dark modules in a row become one rectangle.
+			run := 0
+			for x+run < c.width() && c.dark(x+run, y) {
+				run++
+			}
+			if run > 0 {
+				fmt.Fprintf(bw, "M%d %dh%dv1h-%dz", x, y, run, run)
+				x += run
+			}
+		}
+	}
+	bw.WriteString(`"/></svg>`)
+	return bw.Flush()
+}
+
+// ParseColor parses a hex RGB color, with or without a leading #.
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, ErrInvalidColor
+	}
+
+	rgb, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, ErrInvalidColor
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+}
+
+// Hex formats a color as ParseColor accepts it, with a leading #.
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,73 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"rsc.io/qr"
+)
+
+var (
+	black = color.RGBA{A: 0xff}
+	white = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+func TestPNG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, PNG(&buf, "http://localhost:8080/expand/abc123", Options{
+		Level: qr.M, Size: 256, Margin: 4, Foreground: black, Background: white,
+	}))
+
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+
+	// Version 3 codes are 29 modules wide, 37 with the margin, which
+	// fits 6 pixels per module into 256.
+	assert.Equal(t, 37*6, img.Bounds().Dx())
+	assert.Equal(t, img.Bounds().Dx(), img.Bounds().Dy())
+	assert.Equal(t, white, color.RGBAModel.Convert(img.At(4*6-1, 4*6-1)), "margin should be background")
+	assert.Equal(t, black, color.RGBAModel.Convert(img.At(4*6, 4*6)), "finder pattern should be foreground")
+}
+
+func TestSVG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, SVG(&buf, "http://localhost:8080/expand/abc123", Options{
+		Level: qr.H, Size: 300, Margin: 0, Foreground: color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}, Background: white,
+	}))
+
+	svg := buf.String()
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="300" height="300"`))
+	assert.Contains(t, svg, `fill="#123456" d="M0 0h7v1h-7z`, "finder pattern should start the path")
+	assert.Contains(t, svg, `fill="#ffffff"`)
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.RGBA
+		wantErr bool
+	}{
+		{in: "000000", want: black},
+		{in: "#fff", want: white},
+		{in: "1A2b3C", want: color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}},
+		{in: "12345", wantErr: true},
+		{in: "gggggg", wantErr: true},
+		{in: "+12345", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseColor(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidColor)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
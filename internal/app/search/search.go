@@ -0,0 +1,212 @@
+// Package search is an in-memory index for finding links by prefix or
+// substring of their alias, title and destination.
+package search
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Document is the searchable part of a link.
+type Document struct {
+	// ID is the short link alias.
+	ID    string
+	Title string
+	Host  string
+	Path  string
+}
+
+// Hit is a document matching a query, a higher score ranks first.
+type Hit struct {
+	ID    string
+	Score int
+}
+
+// Fields weigh matches in the order of Document: the alias is the most
+// specific, the path the least.
+var fieldWeights = [...]int{4, 3, 2, 1}
+
+const (
+	scoreExact     = 8
+	scorePrefix    = 4
+	scoreSubstring = 1
+)
+
+const gramLength = 3
+
+type document struct {
+	fields [len(fieldWeights)]string
+}
+
+// Index is not safe for concurrent use, its users synchronize access.
+type Index struct {
+	docs  map[string]document
+	grams map[string]map[string]struct{}
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:  make(map[string]document),
+		grams: make(map[string]map[string]struct{}),
+	}
+}
+
+// Add indexes the document, replacing any document with the same ID.
+func (ix *Index) Add(doc Document) {
+	ix.Remove(doc.ID)
+
+	d := document{fields: [...]string{
+		strings.ToLower(doc.ID),
+		strings.ToLower(doc.Title),
+		strings.ToLower(doc.Host),
+		strings.ToLower(doc.Path),
+	}}
+	ix.docs[doc.ID] = d
+
+	for gram := range d.grams() {
+		ids, ok := ix.grams[gram]
+		if !ok {
+			ids = make(map[string]struct{})
+			ix.grams[gram] = ids
+		}
+		ids[doc.ID] = struct{}{}
+	}
+}
+
+func (ix *Index) Remove(id string) {
+	d, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+
+	for gram := range d.grams() {
+		ids := ix.grams[gram]
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(ix.grams, gram)
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// Search returns the documents containing every whitespace separated term
+// of the query, ignoring case, best match first. Terms long enough to have
+// trigrams narrow the candidates down through the index, shorter ones are
+// checked against the candidates only.
+func (ix *Index) Search(query string) []Hit {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var candidates map[string]struct{}
+	narrowed := false
+	for _, term := range terms {
+		for _, gram := range grams(term) {
+			candidates = intersect(candidates, ix.grams[gram], narrowed)
+			narrowed = true
+		}
+	}
+
+	hits := []Hit{}
+	match := func(id string, d document) {
+		score := 0
+		for _, term := range terms {
+			termScore := d.score(term)
+			if termScore == 0 {
+				return
+			}
+			score += termScore
+		}
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	if narrowed {
+		for id := range candidates {
+			match(id, ix.docs[id])
+		}
+	} else {
+		for id, d := range ix.docs {
+			match(id, d)
+		}
+	}
+
+	slices.SortFunc(hits, func(a, b Hit) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), strings.Compare(a.ID, b.ID))
+	})
+	return hits
+}
+
+func (d document) score(term string) int {
+	score := 0
+	for i, field := range d.fields {
+		score += fieldWeights[i] * matchScore(field, term)
+	}
+	return score
+}
+
+func matchScore(field, term string) int {
+	switch {
+	case field == term:
+		return scoreExact
+	case strings.HasPrefix(field, term):
+		return scorePrefix
+	}
+
+	for _, word := range words(field) {
+		if strings.HasPrefix(word, term) {
+			return scorePrefix
+		}
+	}
+
+	if strings.Contains(field, term) {
+		return scoreSubstring
+	}
+	return 0
+}
+
+func (d document) grams() map[string]struct{} {
+	all := make(map[string]struct{})
+	for _, field := range d.fields {
+		for _, gram := range grams(field) {
+			all[gram] = struct{}{}
+		}
+	}
+	return all
+}
+
+func grams(s string) []string {
+	runes := []rune(s)
+	var result []string
+	for i := 0; i+gramLength <= len(runes); i++ {
+		result = append(result, string(runes[i:i+gramLength]))
+	}
+	return result
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// intersect returns the IDs in both sets, or a copy of ids if candidates
+// has not been narrowed yet.
+func intersect(candidates, ids map[string]struct{}, narrowed bool) map[string]struct{} {
+	result := make(map[string]struct{})
+	if !narrowed {
+		for id := range ids {
+			result[id] = struct{}{}
+		}
+		return result
+	}
+
+	for id := range candidates {
+		if _, ok := ids[id]; ok {
+			result[id] = struct{}{}
+		}
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(Document{ID: "abc123", Title: "Pricing page", Host: "example.com", Path: "/pricing"})
+	ix.Add(Document{ID: "def456", Title: "Docs", Host: "docs.example.com", Path: "/guides/pricing-faq"})
+	ix.Add(Document{ID: "ghi789", Title: "Blog", Host: "blog.example.org", Path: "/2024/launch"})
+	ix.Add(Document{ID: "removed", Title: "Pricing", Host: "example.com", Path: "/old"})
+	ix.Remove("removed")
+
+	tests := []struct {
+		name        string
+		query       string
+		expectedIDs []string
+	}{
+		{name: "Title prefix ranks above path", query: "pric", expectedIDs: []string{"abc123", "def456"}},
+		{name: "Word prefix inside path", query: "faq", expectedIDs: []string{"def456"}},
+		{name: "Substring", query: "aunc", expectedIDs: []string{"ghi789"}},
+		{name: "Alias", query: "DEF4", expectedIDs: []string{"def456"}},
+		{name: "Host", query: "example.org", expectedIDs: []string{"ghi789"}},
+		{name: "Short term scans all documents", query: "do", expectedIDs: []string{"def456"}},
+		{name: "All terms must match", query: "docs pricing", expectedIDs: []string{"def456"}},
+		{name: "No match", query: "missing", expectedIDs: []string{}},
+		{name: "Empty query", query: "  ", expectedIDs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []string{}
+			for _, hit := range ix.Search(tt.query) {
+				ids = append(ids, hit.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+}
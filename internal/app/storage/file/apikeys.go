@@ -0,0 +1,36 @@
+package file
+
+import (
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/entity"
+)
+
+func (storage *ShortenURLFileStorage) StoreAPIKey(apiKey entity.APIKey) bool {
+	if apiKey.CreatedAt.IsZero() {
+		apiKey.CreatedAt = time.Now().UTC()
+	}
+
+	err := storage.fileWriter.writeAfter(func() (record, error) {
+		if !storage.memoryStorage.StoreAPIKey(apiKey) {
+			return record{}, errNotStored
+		}
+		return record{Type: recordAPIKey, Time: time.Now().UTC(), APIKey: &apiKey}, nil
+	})
+	return err == nil
+}
+
+func (storage *ShortenURLFileStorage) RetrieveAPIKeyByHash(hash string) (entity.APIKey, bool) {
+	return storage.memoryStorage.RetrieveAPIKeyByHash(hash)
+}
+
+func (storage *ShortenURLFileStorage) ListAPIKeys(userID string) []entity.APIKey {
+	return storage.memoryStorage.ListAPIKeys(userID)
+}
+
+func (storage *ShortenURLFileStorage) DeleteAPIKey(id string) error {
+	return storage.fileWriter.writeAfter(func() (record, error) {
+		err := storage.memoryStorage.DeleteAPIKey(id)
+		return record{Type: recordAPIKeyDelete, ID: id, Time: time.Now().UTC()}, err
+	})
+}
@@ -3,37 +3,178 @@ package file
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/leodayo/url-shortener/internal/app/config"
 	"github.com/leodayo/url-shortener/internal/app/entity"
 	"github.com/leodayo/url-shortener/internal/app/storage/memory"
+	"github.com/leodayo/url-shortener/internal/logger"
+	"go.uber.org/zap"
 )
 
+// The storage file is an append-only log with one JSON record per line.
+// Lines written before records had a type hold a bare entity and are read
+// as recordStore.
+type recordType string
+
+const (
+	recordStore  recordType = "store"
+	recordClick  recordType = "click"
+	recordUpdate recordType = "update"
+	recordDelete recordType = "delete"
+
+	// recordWorkspace holds the whole workspace after it was stored or
+	// updated.
+	recordWorkspace       recordType = "workspace"
+	recordWorkspaceDelete recordType = "workspace_delete"
+
+	recordAPIKey       recordType = "api_key"
+	recordAPIKeyDelete recordType = "api_key_delete"
+)
+
+type record struct {
+	Type   recordType         `json:"type"`
+	ID     string             `json:"id,omitempty"`
+	Time   time.Time          `json:"time,omitempty"`
+	Entity *entity.ShortenURL `json:"entity,omitempty"`
+
+	Workspace *entity.Workspace `json:"workspace,omitempty"`
+	APIKey    *entity.APIKey    `json:"api_key,omitempty"`
+}
+
+// errNotStored makes writeAfter skip the record of an entity the memory
+// storage refused or found a duplicate of.
+var errNotStored = errors.New("not stored")
+
 type ShortenURLFileStorage struct {
-	memoryStorage memory.ShortenURLMemoryStorage
-	fileWriter    FileWriter
+	memoryStorage *memory.ShortenURLMemoryStorage
+	fileWriter    *FileWriter
 }
 
 type FileWriter struct {
+	mu      sync.Mutex
 	file    *os.File
 	encoder *json.Encoder
 }
 
-func (storage *ShortenURLFileStorage) Store(entity entity.ShortenURL) bool {
-	ok := storage.memoryStorage.Store(entity)
+// writeAfter runs change and writes the record it returns while holding
+// the file lock, so that records of concurrent changes are written in the
+// order the changes were made. Nothing is written if change fails.
+func (fw *FileWriter) writeAfter(change func() (record, error)) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
 
-	if ok {
-		storage.fileWriter.encoder.Encode(&entity)
+	r, err := change()
+	if err != nil {
+		return err
+	}
+	fw.encode(r)
+	return nil
+}
+
+func (fw *FileWriter) encode(r record) {
+	if err := fw.encoder.Encode(&r); err != nil {
+		logger.Log.Error("cannot write storage record", zap.String("type", string(r.Type)), zap.Error(err))
 	}
+}
 
-	return ok
+func (storage *ShortenURLFileStorage) Store(e entity.ShortenURL) bool {
+	err := storage.fileWriter.writeAfter(func() (record, error) {
+		if !storage.memoryStorage.Store(e) {
+			return record{}, errNotStored
+		}
+		// Recorded as stored, so that the record keeps the CreatedAt
+		// stamped by the memory storage.
+		stored, _ := storage.memoryStorage.Retrieve(e.ID)
+		return record{Type: recordStore, Entity: &stored}, nil
+	})
+	return err == nil
+}
+
+// StoreUnique stores the entity like the memory storage does and appends
+// it to the file if it was created.
+func (storage *ShortenURLFileStorage) StoreUnique(e entity.ShortenURL, duplicate func(existing entity.ShortenURL) bool) (stored entity.ShortenURL, created bool, err error) {
+	err = storage.fileWriter.writeAfter(func() (record, error) {
+		stored, created, err = storage.memoryStorage.StoreUnique(e, duplicate)
+		if err == nil && !created {
+			return record{}, errNotStored
+		}
+		return record{Type: recordStore, Entity: &stored}, err
+	})
+	if errors.Is(err, errNotStored) {
+		err = nil
+	}
+	return stored, created, err
 }
 
 func (storage *ShortenURLFileStorage) Retrieve(key string) (e entity.ShortenURL, ok bool) {
 	return storage.memoryStorage.Retrieve(key)
 }
 
+func (storage *ShortenURLFileStorage) RetrieveByOriginalURL(ownerID, originalURL string) (e entity.ShortenURL, ok bool) {
+	return storage.memoryStorage.RetrieveByOriginalURL(ownerID, originalURL)
+}
+
+// RecordClick counts a click and appends it to the file, so that click
+// limits survive a restart.
+func (storage *ShortenURLFileStorage) RecordClick(key string) (e entity.ShortenURL, err error) {
+	err = storage.fileWriter.writeAfter(func() (record, error) {
+		now := time.Now().UTC()
+		e, err = storage.memoryStorage.RecordClickAt(key, now)
+		return record{Type: recordClick, ID: key, Time: now}, err
+	})
+	return e, err
+}
+
+// Update changes the entity and appends the updated entity to the file.
+func (storage *ShortenURLFileStorage) Update(key string, update func(entity.ShortenURL) (entity.ShortenURL, error)) (e entity.ShortenURL, err error) {
+	err = storage.fileWriter.writeAfter(func() (record, error) {
+		e, err = storage.memoryStorage.Update(key, update)
+		return record{Type: recordUpdate, ID: key, Time: time.Now().UTC(), Entity: &e}, err
+	})
+	return e, err
+}
+
+func (storage *ShortenURLFileStorage) Delete(key string) error {
+	return storage.DeleteIf(key, nil)
+}
+
+// DeleteIf deletes the entity if check accepts it and appends the deletion
+// to the file.
+func (storage *ShortenURLFileStorage) DeleteIf(key string, check func(entity.ShortenURL) error) error {
+	return storage.fileWriter.writeAfter(func() (record, error) {
+		err := storage.memoryStorage.DeleteIf(key, check)
+		return record{Type: recordDelete, ID: key, Time: time.Now().UTC()}, err
+	})
+}
+
+func (storage *ShortenURLFileStorage) Count() int {
+	return storage.memoryStorage.Count()
+}
+
+func (storage *ShortenURLFileStorage) List() []entity.ShortenURL {
+	return storage.memoryStorage.List()
+}
+
+func (storage *ShortenURLFileStorage) ListByOwner(ownerID string, tags ...string) []entity.ShortenURL {
+	return storage.memoryStorage.ListByOwner(ownerID, tags...)
+}
+
+func (storage *ShortenURLFileStorage) ListByWorkspace(workspaceID string, tags ...string) []entity.ShortenURL {
+	return storage.memoryStorage.ListByWorkspace(workspaceID, tags...)
+}
+
+// Search uses the index of the memory storage, which is rebuilt while the
+// file is loaded.
+func (storage *ShortenURLFileStorage) Search(query string) []entity.ShortenURL {
+	return storage.memoryStorage.Search(query)
+}
+
 func CreateStorage() (*ShortenURLFileStorage, error) {
 	file, err := os.OpenFile(config.FileStoragePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
@@ -46,8 +187,8 @@ func CreateStorage() (*ShortenURLFileStorage, error) {
 	}
 
 	fileStorage := &ShortenURLFileStorage{
-		memoryStorage: *memory.CreateStorage(),
-		fileWriter:    *fw,
+		memoryStorage: memory.CreateStorage(),
+		fileWriter:    fw,
 	}
 
 	if err := loadFromDisk(fileStorage); err != nil {
@@ -62,18 +203,104 @@ func loadFromDisk(fileStorage *ShortenURLFileStorage) error {
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		entry := scanner.Bytes()
+	// A decoder rather than a line scanner, as records of links with long
+	// URLs and histories don't fit the scanner's line limit.
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var entry json.RawMessage
+		if err := decoder.Decode(&entry); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		r := record{}
+		if err := json.Unmarshal(entry, &r); err != nil {
+			return err
+		}
 
-		entity := entity.ShortenURL{}
-		err := json.Unmarshal(entry, &entity)
-		if err != nil {
+		if r.Type == "" {
+			legacyEntity := entity.ShortenURL{}
+			if err := json.Unmarshal(entry, &legacyEntity); err != nil {
+				return err
+			}
+			r = record{Type: recordStore, Entity: &legacyEntity}
+		}
+
+		if err := replay(fileStorage.memoryStorage, r); err != nil {
 			return err
 		}
-		fileStorage.memoryStorage.Store(entity)
 	}
+}
 
+func replay(memoryStorage *memory.ShortenURLMemoryStorage, r record) error {
+	switch r.Type {
+	case recordStore:
+		if r.Entity == nil {
+			return errors.New("store record without entity")
+		}
+		memoryStorage.Store(*r.Entity)
+	case recordClick:
+		// A click on a link that no longer exists is not an error.
+		if _, err := memoryStorage.RecordClickAt(r.ID, r.Time); err != nil && !errors.Is(err, entity.ErrNotFound) {
+			return err
+		}
+	case recordUpdate:
+		if r.Entity == nil {
+			return errors.New("update record without entity")
+		}
+		// Clicks are counted by their own records, which may have been
+		// written before the update record of an earlier state.
+		_, err := memoryStorage.Update(r.ID, func(current entity.ShortenURL) (entity.ShortenURL, error) {
+			updated := *r.Entity
+			updated.Clicks = current.Clicks
+			updated.DailyClicks = current.DailyClicks
+			return updated, nil
+		})
+		if err != nil && !errors.Is(err, entity.ErrNotFound) {
+			return err
+		}
+	case recordWorkspace:
+		if r.Workspace == nil {
+			return errors.New("workspace record without workspace")
+		}
+		if !memoryStorage.StoreWorkspace(*r.Workspace) {
+			_, err := memoryStorage.UpdateWorkspace(r.Workspace.ID, func(entity.Workspace) (entity.Workspace, error) {
+				return *r.Workspace, nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	case recordWorkspaceDelete:
+		err := memoryStorage.DeleteWorkspace(r.ID)
+		if errors.Is(err, entity.ErrWorkspaceNotEmpty) {
+			// Files written before emptiness was checked under the lock
+			// may delete a workspace that still had links. Keeping it
+			// keeps the links accessible.
+			logger.Log.Warn("keeping deleted workspace that still has links", zap.String("id", r.ID))
+			return nil
+		}
+		if err != nil && !errors.Is(err, entity.ErrWorkspaceNotFound) {
+			return err
+		}
+	case recordAPIKey:
+		if r.APIKey == nil {
+			return errors.New("API key record without key")
+		}
+		memoryStorage.StoreAPIKey(*r.APIKey)
+	case recordAPIKeyDelete:
+		if err := memoryStorage.DeleteAPIKey(r.ID); err != nil && !errors.Is(err, entity.ErrAPIKeyNotFound) {
+			return err
+		}
+	case recordDelete:
+		if err := memoryStorage.Delete(r.ID); err != nil && !errors.Is(err, entity.ErrNotFound) {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown storage record type %q", r.Type)
+	}
 	return nil
 }
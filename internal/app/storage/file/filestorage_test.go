@@ -0,0 +1,162 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/leodayo/url-shortener/internal/app/config"
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClicksSurviveRestart(t *testing.T) {
+	config.FileStoragePath = filepath.Join(t.TempDir(), "storage.json")
+	// A line written before storage records had a type.
+	legacyLine := `{"ID":"legacy","OriginalURL":"https://example.com/legacy"}` + "\n"
+	require.NoError(t, os.WriteFile(config.FileStoragePath, []byte(legacyLine), 0666))
+
+	storage, err := CreateStorage()
+	require.NoError(t, err)
+
+	_, ok := storage.Retrieve("legacy")
+	assert.True(t, ok, "legacy record should be loaded")
+
+	require.True(t, storage.Store(entity.ShortenURL{
+		ID:          "once",
+		OriginalURL: "https://example.com/once",
+		LinkOptions: entity.LinkOptions{MaxClicks: 1},
+	}))
+	_, err = storage.RecordClick("once")
+	require.NoError(t, err)
+
+	reopened, err := CreateStorage()
+	require.NoError(t, err)
+
+	e, ok := reopened.Retrieve("once")
+	require.True(t, ok)
+	assert.Equal(t, int64(1), e.Clicks)
+	_, err = reopened.RecordClick("once")
+	assert.ErrorIs(t, err, entity.ErrClickLimitReached)
+}
+
+func TestUpdateAndDeleteSurviveRestart(t *testing.T) {
+	config.FileStoragePath = filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := CreateStorage()
+	require.NoError(t, err)
+
+	require.True(t, storage.Store(entity.ShortenURL{ID: "link", OriginalURL: "https://example.com/typo", OwnerID: "owner"}))
+	_, err = storage.Update("link", func(e entity.ShortenURL) (entity.ShortenURL, error) {
+		e.OriginalURL = "https://example.com/fixed"
+		return e, nil
+	})
+	require.NoError(t, err)
+	_, err = storage.RecordClick("link")
+	require.NoError(t, err)
+	require.True(t, storage.Store(entity.ShortenURL{ID: "deleted", OriginalURL: "https://example.com/deleted"}))
+	require.NoError(t, storage.Delete("deleted"))
+	unique, created, err := storage.StoreUnique(entity.ShortenURL{ID: "unique", OriginalURL: "https://example.com/unique"}, func(entity.ShortenURL) bool { return true })
+	require.NoError(t, err)
+	require.True(t, created)
+	require.False(t, unique.CreatedAt.IsZero(), "stored entity should be returned")
+
+	reopened, err := CreateStorage()
+	require.NoError(t, err)
+
+	e, ok := reopened.Retrieve("link")
+	require.True(t, ok)
+	assert.Equal(t, "https://example.com/fixed", e.OriginalURL)
+	assert.Equal(t, int64(1), e.Clicks)
+	require.Len(t, e.DailyClicks, 1, "click should be counted on its day")
+	assert.Equal(t, int64(1), e.DailyClicks[0].Clicks)
+	assert.False(t, e.CreatedAt.IsZero(), "creation time should be stored")
+
+	e, ok = reopened.Retrieve("unique")
+	require.True(t, ok)
+	assert.True(t, unique.CreatedAt.Equal(e.CreatedAt), "creation time should be stored as returned")
+
+	_, ok = reopened.Retrieve("deleted")
+	assert.False(t, ok, "deleted link should stay deleted")
+
+	_, ok = reopened.RetrieveByOriginalURL("owner", "https://example.com/typo")
+	assert.False(t, ok, "old URL should no longer be indexed")
+	_, ok = reopened.RetrieveByOriginalURL("owner", "https://example.com/fixed")
+	assert.True(t, ok)
+}
+
+func TestWorkspacesSurviveRestart(t *testing.T) {
+	config.FileStoragePath = filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := CreateStorage()
+	require.NoError(t, err)
+
+	require.True(t, storage.StoreWorkspace(entity.Workspace{
+		ID:      "team",
+		Name:    "Team",
+		Members: []entity.Member{{UserID: "owner", Role: entity.RoleOwner}},
+	}))
+	_, err = storage.UpdateWorkspace("team", func(w entity.Workspace) (entity.Workspace, error) {
+		w.Members = append(w.Members, entity.Member{UserID: "viewer", Role: entity.RoleViewer})
+		return w, nil
+	})
+	require.NoError(t, err)
+	require.True(t, storage.StoreWorkspace(entity.Workspace{ID: "gone", Name: "Gone"}))
+	require.NoError(t, storage.DeleteWorkspace("gone"))
+
+	reopened, err := CreateStorage()
+	require.NoError(t, err)
+
+	workspaces := reopened.ListWorkspaces("viewer")
+	require.Len(t, workspaces, 1)
+	assert.Equal(t, entity.RoleViewer, workspaces[0].Role("viewer"))
+	_, ok := reopened.RetrieveWorkspace("gone")
+	assert.False(t, ok)
+}
+
+func TestRecordsKeepOrderOfConcurrentChanges(t *testing.T) {
+	config.FileStoragePath = filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := CreateStorage()
+	require.NoError(t, err)
+
+	const links = 200
+	var wg sync.WaitGroup
+	for i := range links {
+		id := fmt.Sprintf("link%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			storage.Store(entity.ShortenURL{ID: id, OriginalURL: "https://example.com/" + id})
+		}()
+		go func() {
+			defer wg.Done()
+			// Update as soon as the link can be seen, racing the store
+			// record.
+			for {
+				_, err := storage.Update(id, func(e entity.ShortenURL) (entity.ShortenURL, error) {
+					e.Title = "updated"
+					return e, nil
+				})
+				if !errors.Is(err, entity.ErrNotFound) {
+					return
+				}
+				runtime.Gosched()
+			}
+		}()
+	}
+	wg.Wait()
+
+	reopened, err := CreateStorage()
+	require.NoError(t, err)
+	for i := range links {
+		e, ok := reopened.Retrieve(fmt.Sprintf("link%d", i))
+		require.True(t, ok)
+		assert.Equal(t, "updated", e.Title, "update of %s should be replayed after its store", e.ID)
+	}
+}
@@ -0,0 +1,44 @@
+package file
+
+import (
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/entity"
+)
+
+func (storage *ShortenURLFileStorage) StoreWorkspace(workspace entity.Workspace) bool {
+	if workspace.CreatedAt.IsZero() {
+		workspace.CreatedAt = time.Now().UTC()
+	}
+
+	err := storage.fileWriter.writeAfter(func() (record, error) {
+		if !storage.memoryStorage.StoreWorkspace(workspace) {
+			return record{}, errNotStored
+		}
+		return record{Type: recordWorkspace, Time: time.Now().UTC(), Workspace: &workspace}, nil
+	})
+	return err == nil
+}
+
+func (storage *ShortenURLFileStorage) RetrieveWorkspace(id string) (entity.Workspace, bool) {
+	return storage.memoryStorage.RetrieveWorkspace(id)
+}
+
+func (storage *ShortenURLFileStorage) UpdateWorkspace(id string, update func(entity.Workspace) (entity.Workspace, error)) (workspace entity.Workspace, err error) {
+	err = storage.fileWriter.writeAfter(func() (record, error) {
+		workspace, err = storage.memoryStorage.UpdateWorkspace(id, update)
+		return record{Type: recordWorkspace, Time: time.Now().UTC(), Workspace: &workspace}, err
+	})
+	return workspace, err
+}
+
+func (storage *ShortenURLFileStorage) DeleteWorkspace(id string) error {
+	return storage.fileWriter.writeAfter(func() (record, error) {
+		err := storage.memoryStorage.DeleteWorkspace(id)
+		return record{Type: recordWorkspaceDelete, ID: id, Time: time.Now().UTC()}, err
+	})
+}
+
+func (storage *ShortenURLFileStorage) ListWorkspaces(userID string) []entity.Workspace {
+	return storage.memoryStorage.ListWorkspaces(userID)
+}
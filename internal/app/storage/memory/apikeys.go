@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/entity"
+)
+
+// StoreAPIKey saves the key unless its ID or hash is already taken,
+// stamping CreatedAt if it is not set.
+func (storage *ShortenURLMemoryStorage) StoreAPIKey(apiKey entity.APIKey) bool {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if _, exists := storage.apiKeys[apiKey.ID]; exists {
+		return false
+	}
+	if _, exists := storage.apiKeysByHash[apiKey.Hash]; exists {
+		return false
+	}
+
+	if apiKey.CreatedAt.IsZero() {
+		apiKey.CreatedAt = time.Now().UTC()
+	}
+
+	storage.apiKeys[apiKey.ID] = apiKey
+	storage.apiKeysByHash[apiKey.Hash] = apiKey.ID
+	return true
+}
+
+func (storage *ShortenURLMemoryStorage) RetrieveAPIKeyByHash(hash string) (apiKey entity.APIKey, ok bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	id, ok := storage.apiKeysByHash[hash]
+	if !ok {
+		return apiKey, false
+	}
+	apiKey, ok = storage.apiKeys[id]
+	return apiKey, ok
+}
+
+// ListAPIKeys returns the keys of the user in no particular order.
+func (storage *ShortenURLMemoryStorage) ListAPIKeys(userID string) []entity.APIKey {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	apiKeys := []entity.APIKey{}
+	for _, apiKey := range storage.apiKeys {
+		if apiKey.UserID == userID {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	return apiKeys
+}
+
+func (storage *ShortenURLMemoryStorage) DeleteAPIKey(id string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	apiKey, ok := storage.apiKeys[id]
+	if !ok {
+		return entity.ErrAPIKeyNotFound
+	}
+
+	delete(storage.apiKeysByHash, apiKey.Hash)
+	delete(storage.apiKeys, id)
+	return nil
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"net/url"
+	"slices"
+
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/search"
+)
+
+type idSet map[string]struct{}
+
+func (storage *ShortenURLMemoryStorage) index(e entity.ShortenURL) {
+	addToIndex(storage.byURL, urlKey(e.OwnerID, e.OriginalURL), e.ID)
+	if e.WorkspaceID == "" {
+		addToIndex(storage.byOwner, e.OwnerID, e.ID)
+	} else {
+		addToIndex(storage.byWorkspace, e.WorkspaceID, e.ID)
+	}
+	for _, tag := range e.Tags {
+		addToIndex(storage.byTag, tag, e.ID)
+	}
+
+	storage.search.Add(searchDocument(e))
+}
+
+func (storage *ShortenURLMemoryStorage) unindex(e entity.ShortenURL) {
+	removeFromIndex(storage.byURL, urlKey(e.OwnerID, e.OriginalURL), e.ID)
+	if e.WorkspaceID == "" {
+		removeFromIndex(storage.byOwner, e.OwnerID, e.ID)
+	} else {
+		removeFromIndex(storage.byWorkspace, e.WorkspaceID, e.ID)
+	}
+	for _, tag := range e.Tags {
+		removeFromIndex(storage.byTag, tag, e.ID)
+	}
+
+	storage.search.Remove(e.ID)
+}
+
+// listIndexed returns the entities among candidates that have all of the
+// tags and are kept by keep. The smallest of the candidate and tag sets is
+// scanned.
+func (storage *ShortenURLMemoryStorage) listIndexed(candidates idSet, tags []string, keep func(entity.ShortenURL) bool) []entity.ShortenURL {
+	for _, tag := range tags {
+		if tagged := storage.byTag[tag]; len(tagged) < len(candidates) {
+			candidates = tagged
+		}
+	}
+
+	entities := []entity.ShortenURL{}
+	for id := range candidates {
+		e := storage.byID[id]
+		if keep(e) && hasTags(e, tags) {
+			entities = append(entities, e)
+		}
+	}
+	return entities
+}
+
+// oldest returns the entity among candidates kept by keep that was created
+// first, ties broken by ID.
+func (storage *ShortenURLMemoryStorage) oldest(candidates idSet, keep func(entity.ShortenURL) bool) (oldest entity.ShortenURL, found bool) {
+	for id := range candidates {
+		e := storage.byID[id]
+		if !keep(e) {
+			continue
+		}
+		if !found || e.CreatedAt.Before(oldest.CreatedAt) || (e.CreatedAt.Equal(oldest.CreatedAt) && e.ID < oldest.ID) {
+			oldest, found = e, true
+		}
+	}
+	return oldest, found
+}
+
+func addToIndex(index map[string]idSet, key, id string) {
+	ids, ok := index[key]
+	if !ok {
+		ids = make(idSet)
+		index[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+func removeFromIndex(index map[string]idSet, key, id string) {
+	ids := index[key]
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(index, key)
+	}
+}
+
+func hasTags(e entity.ShortenURL, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(e.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func searchDocument(e entity.ShortenURL) search.Document {
+	doc := search.Document{ID: e.ID, Title: e.Title}
+	if parsedURL, err := url.Parse(e.OriginalURL); err == nil {
+		doc.Host = parsedURL.Hostname()
+		doc.Path = parsedURL.Path
+	}
+	return doc
+}
+
+func urlKey(ownerID, originalURL string) string {
+	return ownerID + "\x00" + originalURL
+}
@@ -2,27 +2,239 @@ package memory
 
 import (
 	"sync"
+	"time"
 
 	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/leodayo/url-shortener/internal/app/search"
 )
 
 type ShortenURLMemoryStorage struct {
-	syncMap sync.Map
+	mu      sync.RWMutex
+	byID    map[string]entity.ShortenURL
+	byURL   map[string]idSet
+	byOwner map[string]idSet
+	byTag   map[string]idSet
+	search  *search.Index
+
+	byWorkspace map[string]idSet
+	workspaces  map[string]entity.Workspace
+	byMember    map[string]idSet
+
+	apiKeys       map[string]entity.APIKey
+	apiKeysByHash map[string]string
+}
+
+// Store saves the entity unless its ID is already taken, stamping
+// CreatedAt if it is not set.
+func (storage *ShortenURLMemoryStorage) Store(e entity.ShortenURL) bool {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	_, ok := storage.store(e)
+	return ok
 }
 
-func (storage *ShortenURLMemoryStorage) Store(entity entity.ShortenURL) bool {
-	_, loaded := storage.syncMap.LoadOrStore(entity.ID, entity)
-	return !loaded
+// StoreUnique stores the entity unless the owner already has one for the
+// same original URL that duplicate accepts. The oldest such duplicate is
+// returned instead, with created false. The lookup and the store happen
+// under one lock, so concurrent calls cannot both create a link. A link
+// cannot be stored in a workspace that doesn't exist.
+func (storage *ShortenURLMemoryStorage) StoreUnique(e entity.ShortenURL, duplicate func(existing entity.ShortenURL) bool) (_ entity.ShortenURL, created bool, err error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if existing, ok := storage.oldest(storage.byURL[urlKey(e.OwnerID, e.OriginalURL)], duplicate); ok {
+		return existing, false, nil
+	}
+	if _, ok := storage.workspaces[e.WorkspaceID]; e.WorkspaceID != "" && !ok {
+		return e, false, entity.ErrWorkspaceNotFound
+	}
+
+	stored, ok := storage.store(e)
+	if !ok {
+		return e, false, entity.ErrIDTaken
+	}
+	return stored, true, nil
+}
+
+// store is Store with the lock held. It returns the entity as stored.
+func (storage *ShortenURLMemoryStorage) store(e entity.ShortenURL) (entity.ShortenURL, bool) {
+	if _, exists := storage.byID[e.ID]; exists {
+		return e, false
+	}
+
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+
+	storage.byID[e.ID] = e
+	storage.index(e)
+	return e, true
 }
 
 func (storage *ShortenURLMemoryStorage) Retrieve(key string) (e entity.ShortenURL, ok bool) {
-	v, ok := storage.syncMap.Load(key)
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	e, ok = storage.byID[key]
+	return e, ok
+}
+
+// RetrieveByOriginalURL returns the oldest entity the owner stored for the
+// original URL.
+func (storage *ShortenURLMemoryStorage) RetrieveByOriginalURL(ownerID, originalURL string) (e entity.ShortenURL, ok bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	return storage.oldest(storage.byURL[urlKey(ownerID, originalURL)], func(entity.ShortenURL) bool { return true })
+}
+
+// RecordClick increments the click counter of the entity and returns it.
+// The click limit is checked under the same lock, so concurrent clicks
+// cannot go over it.
+func (storage *ShortenURLMemoryStorage) RecordClick(key string) (e entity.ShortenURL, err error) {
+	return storage.RecordClickAt(key, time.Now())
+}
+
+// RecordClickAt is RecordClick for a click at the given time, for clicks
+// replayed from a log.
+func (storage *ShortenURLMemoryStorage) RecordClickAt(key string, t time.Time) (e entity.ShortenURL, err error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	e, ok := storage.byID[key]
+	if !ok {
+		return e, entity.ErrNotFound
+	}
+	if e.ClicksExhausted() {
+		return e, entity.ErrClickLimitReached
+	}
+
+	e.Clicks++
+	if !t.IsZero() {
+		e.DailyClicks = e.WithClick(t)
+	}
+	storage.byID[key] = e
+	return e, nil
+}
+
+// Update replaces the entity with the result of update, which runs under
+// the storage lock so it can check the entity it is given. An error from
+// update leaves the entity unchanged and is returned as is.
+func (storage *ShortenURLMemoryStorage) Update(key string, update func(entity.ShortenURL) (entity.ShortenURL, error)) (entity.ShortenURL, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	e, ok := storage.byID[key]
 	if !ok {
-		return e, ok
+		return e, entity.ErrNotFound
+	}
+
+	updated, err := update(e)
+	if err != nil {
+		return e, err
 	}
-	return v.(entity.ShortenURL), ok
+	updated.ID = key
+
+	storage.unindex(e)
+	storage.byID[key] = updated
+	storage.index(updated)
+	return updated, nil
+}
+
+func (storage *ShortenURLMemoryStorage) Delete(key string) error {
+	return storage.DeleteIf(key, nil)
+}
+
+// DeleteIf deletes the entity if check, which runs under the storage lock
+// like the update of Update, accepts it. An error from check keeps the
+// entity and is returned as is.
+func (storage *ShortenURLMemoryStorage) DeleteIf(key string, check func(entity.ShortenURL) error) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	e, ok := storage.byID[key]
+	if !ok {
+		return entity.ErrNotFound
+	}
+	if check != nil {
+		if err := check(e); err != nil {
+			return err
+		}
+	}
+
+	storage.unindex(e)
+	delete(storage.byID, key)
+	return nil
+}
+
+// ListByOwner returns the personal entities of the owner, those outside
+// of any workspace, that have all of the tags. The order is unspecified.
+func (storage *ShortenURLMemoryStorage) ListByOwner(ownerID string, tags ...string) []entity.ShortenURL {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	return storage.listIndexed(storage.byOwner[ownerID], tags, func(e entity.ShortenURL) bool {
+		return e.OwnerID == ownerID && e.WorkspaceID == ""
+	})
+}
+
+// ListByWorkspace returns the entities of the workspace that have all of
+// the tags. The order is unspecified.
+func (storage *ShortenURLMemoryStorage) ListByWorkspace(workspaceID string, tags ...string) []entity.ShortenURL {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	return storage.listIndexed(storage.byWorkspace[workspaceID], tags, func(e entity.ShortenURL) bool {
+		return e.WorkspaceID == workspaceID
+	})
+}
+
+// Search returns the entities matching the query, best match first.
+func (storage *ShortenURLMemoryStorage) Search(query string) []entity.ShortenURL {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	hits := storage.search.Search(query)
+	entities := make([]entity.ShortenURL, 0, len(hits))
+	for _, hit := range hits {
+		entities = append(entities, storage.byID[hit.ID])
+	}
+	return entities
+}
+
+func (storage *ShortenURLMemoryStorage) Count() int {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	return len(storage.byID)
+}
+
+// List returns all stored entities in no particular order.
+func (storage *ShortenURLMemoryStorage) List() []entity.ShortenURL {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	entities := make([]entity.ShortenURL, 0, len(storage.byID))
+	for _, e := range storage.byID {
+		entities = append(entities, e)
+	}
+	return entities
 }
 
 func CreateStorage() *ShortenURLMemoryStorage {
-	return new(ShortenURLMemoryStorage)
+	return &ShortenURLMemoryStorage{
+		byID:    make(map[string]entity.ShortenURL),
+		byURL:   make(map[string]idSet),
+		byOwner: make(map[string]idSet),
+		byTag:   make(map[string]idSet),
+		search:  search.NewIndex(),
+
+		byWorkspace: make(map[string]idSet),
+		workspaces:  make(map[string]entity.Workspace),
+		byMember:    make(map[string]idSet),
+
+		apiKeys:       make(map[string]entity.APIKey),
+		apiKeysByHash: make(map[string]string),
+	}
 }
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/leodayo/url-shortener/internal/app/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordClickRespectsLimitConcurrently(t *testing.T) {
+	const maxClicks = 10
+	storage := CreateStorage()
+	require.True(t, storage.Store(entity.ShortenURL{
+		ID:          "abc123",
+		OriginalURL: "https://example.com",
+		LinkOptions: entity.LinkOptions{MaxClicks: maxClicks},
+	}))
+
+	var succeeded atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := storage.RecordClick("abc123"); err == nil {
+				succeeded.Add(1)
+			} else {
+				assert.ErrorIs(t, err, entity.ErrClickLimitReached)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(maxClicks), succeeded.Load())
+	e, ok := storage.Retrieve("abc123")
+	require.True(t, ok)
+	assert.Equal(t, int64(maxClicks), e.Clicks)
+	assert.True(t, e.ClicksExhausted())
+
+	_, err := storage.RecordClick("missing")
+	assert.ErrorIs(t, err, entity.ErrNotFound)
+}
+
+func TestListByOwnerFollowsTagChanges(t *testing.T) {
+	storage := CreateStorage()
+	require.True(t, storage.Store(entity.ShortenURL{ID: "a", OwnerID: "owner", Tags: []string{"docs"}}))
+	require.True(t, storage.Store(entity.ShortenURL{ID: "b", OwnerID: "owner", Tags: []string{"docs", "launch"}}))
+	require.True(t, storage.Store(entity.ShortenURL{ID: "c", OwnerID: "other", Tags: []string{"docs"}}))
+
+	assert.Len(t, storage.ListByOwner("owner"), 2)
+	assert.Len(t, storage.ListByOwner("owner", "docs"), 2)
+	assert.Len(t, storage.ListByOwner("owner", "docs", "launch"), 1)
+
+	_, err := storage.Update("b", func(e entity.ShortenURL) (entity.ShortenURL, error) {
+		e.Tags = []string{"launch"}
+		return e, nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, storage.ListByOwner("owner", "docs"), 1)
+
+	require.NoError(t, storage.Delete("a"))
+	assert.Empty(t, storage.ListByOwner("owner", "docs"))
+	assert.Len(t, storage.ListByOwner("owner"), 1)
+}
+
+func TestWorkspaceKeepsItsLinks(t *testing.T) {
+	storage := CreateStorage()
+	require.True(t, storage.StoreWorkspace(entity.Workspace{ID: "team"}))
+
+	noDuplicates := func(entity.ShortenURL) bool { return false }
+	_, _, err := storage.StoreUnique(entity.ShortenURL{ID: "orphan", OriginalURL: "https://example.com", WorkspaceID: "gone"}, noDuplicates)
+	assert.ErrorIs(t, err, entity.ErrWorkspaceNotFound, "links cannot be stored in missing workspaces")
+
+	_, created, err := storage.StoreUnique(entity.ShortenURL{ID: "link", OriginalURL: "https://example.com", WorkspaceID: "team"}, noDuplicates)
+	require.NoError(t, err)
+	require.True(t, created)
+	assert.ErrorIs(t, storage.DeleteWorkspace("team"), entity.ErrWorkspaceNotEmpty)
+
+	require.NoError(t, storage.Delete("link"))
+	assert.NoError(t, storage.DeleteWorkspace("team"))
+}
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/leodayo/url-shortener/internal/app/entity"
+)
+
+// StoreWorkspace saves the workspace unless its ID is already taken,
+// stamping CreatedAt if it is not set.
+func (storage *ShortenURLMemoryStorage) StoreWorkspace(workspace entity.Workspace) bool {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if _, exists := storage.workspaces[workspace.ID]; exists {
+		return false
+	}
+
+	if workspace.CreatedAt.IsZero() {
+		workspace.CreatedAt = time.Now().UTC()
+	}
+
+	storage.workspaces[workspace.ID] = workspace
+	storage.indexMembers(workspace)
+	return true
+}
+
+func (storage *ShortenURLMemoryStorage) RetrieveWorkspace(id string) (workspace entity.Workspace, ok bool) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	workspace, ok = storage.workspaces[id]
+	return workspace, ok
+}
+
+// UpdateWorkspace replaces the workspace with the result of update, which
+// runs under the storage lock. An error from update leaves the workspace
+// unchanged and is returned as is.
+func (storage *ShortenURLMemoryStorage) UpdateWorkspace(id string, update func(entity.Workspace) (entity.Workspace, error)) (entity.Workspace, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	workspace, ok := storage.workspaces[id]
+	if !ok {
+		return workspace, entity.ErrWorkspaceNotFound
+	}
+
+	updated, err := update(workspace)
+	if err != nil {
+		return workspace, err
+	}
+	updated.ID = id
+
+	storage.unindexMembers(workspace)
+	storage.workspaces[id] = updated
+	storage.indexMembers(updated)
+	return updated, nil
+}
+
+// DeleteWorkspace deletes the workspace if it has no links, checked under
+// the same lock so that no link is left in a deleted workspace.
+func (storage *ShortenURLMemoryStorage) DeleteWorkspace(id string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	workspace, ok := storage.workspaces[id]
+	if !ok {
+		return entity.ErrWorkspaceNotFound
+	}
+	if len(storage.byWorkspace[id]) > 0 {
+		return entity.ErrWorkspaceNotEmpty
+	}
+
+	storage.unindexMembers(workspace)
+	delete(storage.workspaces, id)
+	return nil
+}
+
+// ListWorkspaces returns the workspaces the user is a member of, in no
+// particular order.
+func (storage *ShortenURLMemoryStorage) ListWorkspaces(userID string) []entity.Workspace {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	workspaces := []entity.Workspace{}
+	for id := range storage.byMember[userID] {
+		workspaces = append(workspaces, storage.workspaces[id])
+	}
+	return workspaces
+}
+
+func (storage *ShortenURLMemoryStorage) indexMembers(workspace entity.Workspace) {
+	for _, member := range workspace.Members {
+		addToIndex(storage.byMember, member.UserID, workspace.ID)
+	}
+}
+
+func (storage *ShortenURLMemoryStorage) unindexMembers(workspace entity.Workspace) {
+	for _, member := range workspace.Members {
+		removeFromIndex(storage.byMember, member.UserID, workspace.ID)
+	}
+}
@@ -6,18 +6,58 @@ import (
 	"github.com/leodayo/url-shortener/internal/app/storage/memory"
 )
 
-var Repository Storage[string, entity.ShortenURL]
+var (
+	Repository Storage[string, entity.ShortenURL]
+	Workspaces WorkspaceStorage
+	APIKeys    APIKeyStorage
+)
 
 type Storage[K comparable, E any] interface {
 	Store(entity E) bool
+	StoreUnique(entity E, duplicate func(existing E) bool) (E, bool, error)
 	Retrieve(key K) (E, bool)
+	RetrieveByOriginalURL(ownerID, originalURL string) (E, bool)
+	RecordClick(key K) (E, error)
+	Update(key K, update func(E) (E, error)) (E, error)
+	Delete(key K) error
+	DeleteIf(key K, check func(E) error) error
+	Count() int
+	List() []E
+	ListByOwner(ownerID string, tags ...string) []E
+	ListByWorkspace(workspaceID string, tags ...string) []E
+	Search(query string) []E
+}
+
+type WorkspaceStorage interface {
+	StoreWorkspace(workspace entity.Workspace) bool
+	RetrieveWorkspace(id string) (entity.Workspace, bool)
+	UpdateWorkspace(id string, update func(entity.Workspace) (entity.Workspace, error)) (entity.Workspace, error)
+	DeleteWorkspace(id string) error
+	ListWorkspaces(userID string) []entity.Workspace
+}
+
+type APIKeyStorage interface {
+	StoreAPIKey(apiKey entity.APIKey) bool
+	RetrieveAPIKeyByHash(hash string) (entity.APIKey, bool)
+	ListAPIKeys(userID string) []entity.APIKey
+	DeleteAPIKey(id string) error
 }
 
 func ItinInMemoryStorage() {
-	Repository = memory.CreateStorage()
+	memoryStorage := memory.CreateStorage()
+	Repository = memoryStorage
+	Workspaces = memoryStorage
+	APIKeys = memoryStorage
 }
 
-func InitFileStorage() (err error) {
-	Repository, err = file.CreateStorage()
-	return err
+func InitFileStorage() error {
+	fileStorage, err := file.CreateStorage()
+	if err != nil {
+		return err
+	}
+
+	Repository = fileStorage
+	Workspaces = fileStorage
+	APIKeys = fileStorage
+	return nil
 }
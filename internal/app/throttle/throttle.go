@@ -0,0 +1,87 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepThreshold is the number of tracked keys above which expired
+// entries are purged when a new failure is recorded.
+const sweepThreshold = 1024
+
+// Limiter counts failed attempts per key and blocks a key once it has
+// failed maxFailures times within a window. The window starts with the
+// first failure and the key is unblocked when it ends.
+type Limiter struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	failures    map[string]*failures
+	now         func() time.Time
+}
+
+type failures struct {
+	count   int
+	expires time.Time
+}
+
+func NewLimiter(maxFailures int, window time.Duration) *Limiter {
+	return &Limiter{
+		maxFailures: maxFailures,
+		window:      window,
+		failures:    make(map[string]*failures),
+		now:         time.Now,
+	}
+}
+
+// Allow reports whether another attempt may be made for key. If not, it
+// also returns how long the caller has to wait.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.failures[key]
+	if !ok {
+		return true, 0
+	}
+
+	now := l.now()
+	if !now.Before(f.expires) {
+		delete(l.failures, key)
+		return true, 0
+	}
+	if f.count < l.maxFailures {
+		return true, 0
+	}
+	return false, f.expires.Sub(now)
+}
+
+// Fail records a failed attempt for key.
+func (l *Limiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if len(l.failures) > sweepThreshold {
+		for k, f := range l.failures {
+			if !now.Before(f.expires) {
+				delete(l.failures, k)
+			}
+		}
+	}
+
+	f, ok := l.failures[key]
+	if !ok || !now.Before(f.expires) {
+		f = &failures{expires: now.Add(l.window)}
+		l.failures[key] = f
+	}
+	f.count++
+}
+
+// Reset forgets the failures of key, e.g. after a successful attempt.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, key)
+}
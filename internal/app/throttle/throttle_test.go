@@ -0,0 +1,36 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(3, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := limiter.Allow("abc")
+		assert.True(t, ok, "attempt %d should be allowed", i+1)
+		limiter.Fail("abc")
+	}
+
+	ok, retryAfter := limiter.Allow("abc")
+	assert.False(t, ok)
+	assert.Equal(t, time.Minute, retryAfter)
+
+	ok, _ = limiter.Allow("other")
+	assert.True(t, ok, "keys are throttled independently")
+
+	now = now.Add(time.Minute)
+	ok, _ = limiter.Allow("abc")
+	assert.True(t, ok, "key is unblocked once the window ends")
+
+	limiter.Fail("abc")
+	limiter.Reset("abc")
+	ok, _ = limiter.Allow("abc")
+	assert.True(t, ok)
+}
@@ -0,0 +1,126 @@
+package urlnorm
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrInvalidURL       = errors.New("invalid URL")
+	ErrSchemeNotAllowed = errors.New("URL scheme not allowed")
+	ErrPrivateTarget    = errors.New("URL points to a private or loopback address")
+)
+
+// Options control what Normalize accepts.
+type Options struct {
+	// AllowedSchemes lists the accepted schemes in lower case.
+	AllowedSchemes []string
+	// AllowPrivate accepts loopback, private and link-local targets.
+	AllowPrivate bool
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// hostProfile converts internationalized host names to punycode. Unlike
+// idna.Lookup it tolerates underscores, which do occur in real host names.
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+)
+
+// Normalize validates rawURL and returns it in canonical form: scheme and
+// host in lower case, internationalized host names in punycode and default
+// ports removed. The path, query (including parameter order) and fragment
+// are kept exactly as given.
+func Normalize(rawURL string, opts Options) (string, error) {
+	parsedURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsedURL.Scheme == "" {
+		return "", ErrInvalidURL
+	}
+
+	scheme := strings.ToLower(parsedURL.Scheme)
+	if !slices.Contains(opts.AllowedSchemes, scheme) {
+		return "", ErrSchemeNotAllowed
+	}
+
+	hostname := strings.TrimSuffix(parsedURL.Hostname(), ".")
+	if hostname == "" {
+		return "", ErrInvalidURL
+	}
+
+	host, addr, err := normalizeHost(hostname)
+	if err != nil {
+		return "", err
+	}
+	if !opts.AllowPrivate && isPrivateTarget(host, addr) {
+		return "", ErrPrivateTarget
+	}
+
+	if addr.Is6() {
+		host = "[" + host + "]"
+	}
+	if port := parsedURL.Port(); port != "" && port != defaultPorts[scheme] {
+		host += ":" + port
+	}
+
+	parsedURL.Scheme = scheme
+	parsedURL.Host = host
+	return parsedURL.String(), nil
+}
+
+// normalizeHost returns the canonical form of a host name. If the host is
+// an IP address, it's returned as well.
+func normalizeHost(hostname string) (string, netip.Addr, error) {
+	if addr, err := netip.ParseAddr(hostname); err == nil {
+		addr = addr.Unmap()
+		return addr.String(), addr, nil
+	}
+
+	// Browsers read hosts ending in a numeric label, such as "2130706433"
+	// or "0x7f.1", as IPv4 addresses. Refuse the forms we can't interpret
+	// ourselves instead of letting them slip past the private target check.
+	labels := strings.Split(hostname, ".")
+	if isNumericLabel(labels[len(labels)-1]) {
+		return "", netip.Addr{}, ErrInvalidURL
+	}
+
+	host, err := hostProfile.ToASCII(hostname)
+	if err != nil {
+		return "", netip.Addr{}, ErrInvalidURL
+	}
+	return strings.ToLower(host), netip.Addr{}, nil
+}
+
+func isNumericLabel(label string) bool {
+	lower := strings.ToLower(label)
+	if strings.HasPrefix(lower, "0x") {
+		return true
+	}
+	return lower != "" && strings.Trim(lower, "0123456789") == ""
+}
+
+func isPrivateTarget(host string, addr netip.Addr) bool {
+	if addr.IsValid() {
+		return addr.IsLoopback() ||
+			addr.IsPrivate() ||
+			addr.IsLinkLocalUnicast() ||
+			addr.IsLinkLocalMulticast() ||
+			addr.IsInterfaceLocalMulticast() ||
+			addr.IsUnspecified()
+	}
+
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
@@ -0,0 +1,56 @@
+package urlnorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	opts := Options{AllowedSchemes: []string{"http", "https"}}
+
+	tests := []struct {
+		name          string
+		rawURL        string
+		opts          Options
+		expectedURL   string
+		expectedError error
+	}{
+		{name: "Already normal", rawURL: "https://example.com", opts: opts, expectedURL: "https://example.com"},
+		{name: "Scheme and host lowercased", rawURL: "HTTPS://Example.COM/Path", opts: opts, expectedURL: "https://example.com/Path"},
+		{name: "Default port removed", rawURL: "http://example.com:80/a", opts: opts, expectedURL: "http://example.com/a"},
+		{name: "Default https port removed", rawURL: "https://example.com:443", opts: opts, expectedURL: "https://example.com"},
+		{name: "Custom port kept", rawURL: "https://example.com:8443/", opts: opts, expectedURL: "https://example.com:8443/"},
+		{name: "Query order preserved", rawURL: "https://example.com/?b=2&a=1&b=1", opts: opts, expectedURL: "https://example.com/?b=2&a=1&b=1"},
+		{name: "IDN converted to punycode", rawURL: "https://bücher.example/", opts: opts, expectedURL: "https://xn--bcher-kva.example/"},
+		{name: "Trailing dot removed", rawURL: "https://example.com./", opts: opts, expectedURL: "https://example.com/"},
+		{name: "Public IPv6 literal", rawURL: "http://[2001:4860:4860::8888]:80/", opts: opts, expectedURL: "http://[2001:4860:4860::8888]/"},
+		{name: "Not a URL", rawURL: "not a URL", opts: opts, expectedError: ErrInvalidURL},
+		{name: "Missing host", rawURL: "https:///path", opts: opts, expectedError: ErrInvalidURL},
+		{name: "javascript scheme", rawURL: "javascript:alert(1)", opts: opts, expectedError: ErrSchemeNotAllowed},
+		{name: "ftp scheme", rawURL: "ftp://example.com/file", opts: opts, expectedError: ErrSchemeNotAllowed},
+		{name: "ftp scheme allowed", rawURL: "FTP://example.com:21/file", opts: Options{AllowedSchemes: []string{"ftp"}}, expectedURL: "ftp://example.com/file"},
+		{name: "localhost", rawURL: "http://localhost:8080/", opts: opts, expectedError: ErrPrivateTarget},
+		{name: "localhost subdomain", rawURL: "http://app.LOCALHOST/", opts: opts, expectedError: ErrPrivateTarget},
+		{name: "Loopback IPv4", rawURL: "http://127.0.0.1/", opts: opts, expectedError: ErrPrivateTarget},
+		{name: "Loopback IPv6", rawURL: "http://[::1]/", opts: opts, expectedError: ErrPrivateTarget},
+		{name: "IPv4-mapped loopback", rawURL: "http://[::ffff:127.0.0.1]/", opts: opts, expectedError: ErrPrivateTarget},
+		{name: "Private network", rawURL: "http://192.168.1.1/admin", opts: opts, expectedError: ErrPrivateTarget},
+		{name: "Link-local metadata address", rawURL: "http://169.254.169.254/latest", opts: opts, expectedError: ErrPrivateTarget},
+		{name: "Decimal IPv4 form", rawURL: "http://2130706433/", opts: opts, expectedError: ErrInvalidURL},
+		{name: "Hex IPv4 form", rawURL: "http://0x7f.1/", opts: opts, expectedError: ErrInvalidURL},
+		{name: "Private target allowed", rawURL: "http://localhost:8080/", opts: Options{AllowedSchemes: []string{"http"}, AllowPrivate: true}, expectedURL: "http://localhost:8080/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualURL, err := Normalize(tt.rawURL, tt.opts)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedURL, actualURL)
+		})
+	}
+}
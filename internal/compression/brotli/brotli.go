@@ -0,0 +1,33 @@
+package brotli
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/leodayo/url-shortener/internal/compression"
+)
+
+// Level trades compression ratio for speed. Levels above 6 are too slow
+// for compressing responses on the fly.
+const Level = 5
+
+// Codec implements compression.Codec for the "br" content coding.
+var Codec compression.Codec = codec{}
+
+var writerPool = compression.NewWriterPool(func() *brotli.Writer {
+	return brotli.NewWriterLevel(nil, Level)
+})
+
+type codec struct{}
+
+func (codec) Encoding() string {
+	return "br"
+}
+
+func (codec) NewWriter(w io.Writer) io.WriteCloser {
+	return writerPool.Get(w)
+}
+
+func (codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
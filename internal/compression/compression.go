@@ -0,0 +1,111 @@
+package compression
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec is a content coding usable both for compressing responses and for
+// decoding compressed request bodies.
+type Codec interface {
+	// Encoding is the Content-Encoding token of the codec, e.g. "gzip".
+	Encoding() string
+	// NewWriter returns a compressing writer. Closing it flushes the stream
+	// and may return the underlying encoder to a pool, so it must not be
+	// used afterwards.
+	NewWriter(w io.Writer) io.WriteCloser
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// ResettableWriter is an encoder that can be reused for another stream.
+type ResettableWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// WriterPool keeps encoders around between responses, they are expensive
+// to allocate and hold sizeable internal buffers.
+type WriterPool[W ResettableWriter] struct {
+	pool sync.Pool
+}
+
+func NewWriterPool[W ResettableWriter](newWriter func() W) *WriterPool[W] {
+	return &WriterPool[W]{
+		pool: sync.Pool{New: func() any { return newWriter() }},
+	}
+}
+
+// Get returns an encoder writing into w. It goes back to the pool on Close.
+func (p *WriterPool[W]) Get(w io.Writer) io.WriteCloser {
+	zw := p.pool.Get().(W)
+	zw.Reset(w)
+	return &pooledWriter[W]{zw: zw, pool: p}
+}
+
+type pooledWriter[W ResettableWriter] struct {
+	zw   W
+	pool *WriterPool[W]
+}
+
+func (pw *pooledWriter[W]) Write(p []byte) (int, error) {
+	return pw.zw.Write(p)
+}
+
+func (pw *pooledWriter[W]) Close() error {
+	err := pw.zw.Close()
+	// Detach from the response so the pool doesn't keep it alive.
+	pw.zw.Reset(io.Discard)
+	pw.pool.pool.Put(pw.zw)
+	return err
+}
+
+// Negotiate picks the codec preferred by the client according to the
+// Accept-Encoding header values. Ties are resolved by the order of codecs,
+// which should list the server's preferred codec first. Nil is returned
+// when the client accepts none of them.
+func Negotiate(acceptEncoding []string, codecs []Codec) Codec {
+	qValues := parseAcceptEncoding(acceptEncoding)
+	wildcard, hasWildcard := qValues["*"]
+
+	var best Codec
+	bestQ := 0.0
+	for _, codec := range codecs {
+		q, ok := qValues[codec.Encoding()]
+		if !ok && hasWildcard {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = codec, q
+		}
+	}
+	return best
+}
+
+func parseAcceptEncoding(acceptEncoding []string) map[string]float64 {
+	qValues := make(map[string]float64)
+	for _, value := range acceptEncoding {
+		for _, element := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(element, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if ok && strings.EqualFold(key, "q") {
+					parsedQ, err := strconv.ParseFloat(val, 64)
+					if err != nil {
+						parsedQ = 0
+					}
+					q = parsedQ
+				}
+			}
+			qValues[name] = q
+		}
+	}
+	return qValues
+}
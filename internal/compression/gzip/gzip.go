@@ -3,62 +3,76 @@ package gzip
 import (
 	"compress/gzip"
 	"io"
-	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/leodayo/url-shortener/internal/compression"
+)
+
+// Codec implements compression.Codec for the "gzip" content coding.
+var Codec compression.Codec = codec{}
+
+var (
+	writerPool atomic.Pointer[compression.WriterPool[*gzip.Writer]]
+	readerPool sync.Pool
 )
 
-type compressWriter struct {
-	w  http.ResponseWriter
-	zw *gzip.Writer
+func init() {
+	SetLevel(gzip.DefaultCompression)
 }
 
-func NewCompressWriter(w http.ResponseWriter) *compressWriter {
-	return &compressWriter{
-		w:  w,
-		zw: gzip.NewWriter(w),
+// SetLevel changes the compression level of the writers handed out from
+// now on. Accepts the levels of compress/gzip.
+func SetLevel(level int) error {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		return err
 	}
-}
 
-func (c *compressWriter) Header() http.Header {
-	return c.w.Header()
+	writerPool.Store(compression.NewWriterPool(func() *gzip.Writer {
+		zw, _ := gzip.NewWriterLevel(nil, level)
+		return zw
+	}))
+	return nil
 }
 
-func (c *compressWriter) Write(p []byte) (int, error) {
-	return c.zw.Write(p)
-}
+type codec struct{}
 
-func (c *compressWriter) WriteHeader(statusCode int) {
-	c.w.Header().Set("Content-Encoding", "gzip")
-	c.w.WriteHeader(statusCode)
+func (codec) Encoding() string {
+	return "gzip"
 }
 
-func (c *compressWriter) Close() error {
-	return c.zw.Close()
+func (codec) NewWriter(w io.Writer) io.WriteCloser {
+	return writerPool.Load().Get(w)
 }
 
-type compressReader struct {
-	r  io.ReadCloser
-	zr *gzip.Reader
-}
+// NewReader returns a pooled reader, it goes back to the pool on Close.
+func (codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	zr, ok := readerPool.Get().(*gzip.Reader)
+	if !ok {
+		newReader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &pooledReader{zr: newReader}, nil
+	}
 
-func NewCompressReader(r io.ReadCloser) (*compressReader, error) {
-	zr, err := gzip.NewReader(r)
-	if err != nil {
+	if err := zr.Reset(r); err != nil {
+		readerPool.Put(zr)
 		return nil, err
 	}
+	return &pooledReader{zr: zr}, nil
+}
 
-	return &compressReader{
-		r:  r,
-		zr: zr,
-	}, nil
+type pooledReader struct {
+	zr *gzip.Reader
 }
 
-func (c compressReader) Read(p []byte) (n int, err error) {
-	return c.zr.Read(p)
+func (pr *pooledReader) Read(p []byte) (int, error) {
+	return pr.zr.Read(p)
 }
 
-func (c *compressReader) Close() error {
-	if err := c.r.Close(); err != nil {
-		return err
-	}
-	return c.zr.Close()
+func (pr *pooledReader) Close() error {
+	err := pr.zr.Close()
+	readerPool.Put(pr.zr)
+	return err
 }
@@ -0,0 +1,87 @@
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var payload = []byte(strings.Repeat(`{"result":"http://localhost:8080/expand/abcdef"}`, 64))
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, level := range []int{gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression} {
+		require.NoError(t, SetLevel(level))
+
+		for i := 0; i < 3; i++ {
+			var compressed bytes.Buffer
+			zw := Codec.NewWriter(&compressed)
+			_, err := zw.Write(payload)
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+
+			zr, err := Codec.NewReader(&compressed)
+			require.NoError(t, err)
+			decompressed, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			require.NoError(t, zr.Close())
+
+			assert.Equal(t, payload, decompressed)
+		}
+	}
+	require.NoError(t, SetLevel(gzip.DefaultCompression))
+}
+
+func TestSetLevelRejectsInvalidLevel(t *testing.T) {
+	assert.Error(t, SetLevel(42))
+}
+
+func BenchmarkWriter(b *testing.B) {
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			zw := Codec.NewWriter(io.Discard)
+			zw.Write(payload)
+			zw.Close()
+		}
+	})
+
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			zw := gzip.NewWriter(io.Discard)
+			zw.Write(payload)
+			zw.Close()
+		}
+	})
+}
+
+func BenchmarkReader(b *testing.B) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(payload)
+	zw.Close()
+	data := compressed.Bytes()
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			zr, _ := Codec.NewReader(bytes.NewReader(data))
+			io.Copy(io.Discard, zr)
+			zr.Close()
+		}
+	})
+
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			zr, _ := gzip.NewReader(bytes.NewReader(data))
+			io.Copy(io.Discard, zr)
+			zr.Close()
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package compression
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ResponseWriter buffers the beginning of a response so that compression
+// is only applied once it is known to pay off: for successful responses
+// of a compressible content type that are at least minSize bytes long.
+type ResponseWriter struct {
+	w       http.ResponseWriter
+	codec   Codec
+	zw      io.WriteCloser
+	minSize int
+
+	buf      []byte
+	status   int
+	decided  bool
+	compress bool
+}
+
+func NewResponseWriter(w http.ResponseWriter, codec Codec, minSize int) *ResponseWriter {
+	return &ResponseWriter{
+		w:       w,
+		codec:   codec,
+		minSize: minSize,
+	}
+}
+
+func (c *ResponseWriter) Header() http.Header {
+	return c.w.Header()
+}
+
+func (c *ResponseWriter) Write(p []byte) (int, error) {
+	if c.decided {
+		if c.compress {
+			return c.zw.Write(p)
+		}
+		return c.w.Write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= c.minSize {
+		if err := c.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (c *ResponseWriter) WriteHeader(statusCode int) {
+	if c.decided || c.status != 0 {
+		return
+	}
+
+	c.status = statusCode
+	if !isCompressibleStatus(statusCode) {
+		c.decide()
+	}
+}
+
+// Close flushes any buffered data and finishes the compressed stream.
+func (c *ResponseWriter) Close() error {
+	if !c.decided {
+		if c.status == 0 && len(c.buf) == 0 {
+			// Nothing was written, let net/http send its implicit 200.
+			return nil
+		}
+		if err := c.decide(); err != nil {
+			return err
+		}
+	}
+
+	if c.compress {
+		return c.zw.Close()
+	}
+	return nil
+}
+
+func (c *ResponseWriter) decide() error {
+	c.decided = true
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+
+	header := c.w.Header()
+	if header.Get("Content-Type") == "" && len(c.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(c.buf))
+	}
+
+	c.compress = isCompressibleStatus(c.status) &&
+		len(c.buf) >= c.minSize &&
+		header.Get("Content-Encoding") == "" &&
+		IsCompressibleContentType(header.Get("Content-Type"))
+
+	if c.compress {
+		header.Set("Content-Encoding", c.codec.Encoding())
+		header.Del("Content-Length")
+		c.zw = c.codec.NewWriter(c.w)
+	}
+	c.w.WriteHeader(c.status)
+
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if c.compress {
+		_, err := c.zw.Write(buf)
+		return err
+	}
+	_, err := c.w.Write(buf)
+	return err
+}
+
+func isCompressibleStatus(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300 &&
+		statusCode != http.StatusNoContent &&
+		statusCode != http.StatusPartialContent
+}
+
+// IsCompressibleContentType reports whether responses of the given media
+// type are textual enough to benefit from compression.
+func IsCompressibleContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package zstd
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/leodayo/url-shortener/internal/compression"
+)
+
+// Codec implements compression.Codec for the "zstd" content coding.
+var Codec compression.Codec = codec{}
+
+var writerPool = compression.NewWriterPool(func() *zstd.Encoder {
+	// A single goroutine per encoder, the concurrency comes from serving
+	// many responses at once.
+	zw, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	return zw
+})
+
+// maxMemory caps the window and the decoded size of readers. The default
+// is the window size zstd decoders are expected to support.
+var maxMemory atomic.Uint64
+
+func init() {
+	maxMemory.Store(8 << 20)
+}
+
+// SetMaxMemory caps the memory of the readers handed out from now on, so
+// that a small body declaring a huge window can't make them allocate it.
+// Set it to the limit of decoded bodies. The window cap stays within the
+// bounds zstd allows.
+func SetMaxMemory(n int64) {
+	maxMemory.Store(uint64(max(n, zstd.MinWindowSize)))
+}
+
+type codec struct{}
+
+func (codec) Encoding() string {
+	return "zstd"
+}
+
+func (codec) NewWriter(w io.Writer) io.WriteCloser {
+	return writerPool.Get(w)
+}
+
+func (codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	limit := maxMemory.Load()
+	zr, err := zstd.NewReader(r,
+		zstd.WithDecoderConcurrency(1),
+		zstd.WithDecoderMaxMemory(limit),
+		zstd.WithDecoderMaxWindow(min(limit, zstd.MaxWindowSize)))
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
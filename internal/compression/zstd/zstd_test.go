@@ -0,0 +1,42 @@
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderMemoryLimit(t *testing.T) {
+	// A few KiB of body declaring an 8 MiB window.
+	payload := bytes.Repeat([]byte("a"), 4<<20)
+	var compressed bytes.Buffer
+	zw, err := zstd.NewWriter(&compressed, zstd.WithWindowSize(8<<20), zstd.WithSingleSegment(false))
+	require.NoError(t, err)
+	_, err = zw.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.Less(t, compressed.Len(), 64<<10)
+
+	decode := func() ([]byte, error) {
+		zr, err := Codec.NewReader(bytes.NewReader(compressed.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	}
+	defer SetMaxMemory(8 << 20)
+
+	SetMaxMemory(16 << 20)
+	decompressed, err := decode()
+	require.NoError(t, err)
+	assert.Equal(t, payload, decompressed)
+
+	SetMaxMemory(1 << 20)
+	_, err = decode()
+	assert.ErrorIs(t, err, zstd.ErrWindowSizeExceeded, "the declared window exceeds the limit")
+}
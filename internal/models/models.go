@@ -1,11 +1,160 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL          string     `json:"url"`
+	Interstitial bool       `json:"interstitial,omitempty"`
+	RedirectType int        `json:"redirect_type,omitempty"`
+	ForwardPath  bool       `json:"forward_path,omitempty"`
+	ForwardQuery bool       `json:"forward_query,omitempty"`
+	UTM          *UTMParams `json:"utm,omitempty"`
+	Password     string     `json:"password,omitempty"`
+	MaxClicks    int64      `json:"max_clicks,omitempty"`
+	ActiveFrom   *time.Time `json:"active_from,omitempty"`
+	ActiveUntil  *time.Time `json:"active_until,omitempty"`
+	FallbackURL  string     `json:"fallback_url,omitempty"`
+	Title        string     `json:"title,omitempty"`
+	Notes        string     `json:"notes,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	WorkspaceID  string     `json:"workspace_id,omitempty"`
+}
+
+// UTMParams are merged into the query of the URL being shortened as the
+// corresponding utm_* parameters.
+type UTMParams struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
 }
 
 type ShortenResponse struct {
-	Result string `json:"result"`
+	Result      string `json:"result"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// UpdateURLRequest changes the fields that are present and leaves the
+// others as they are.
+type UpdateURLRequest struct {
+	URL          *string             `json:"url,omitempty"`
+	RedirectType *int                `json:"redirect_type,omitempty"`
+	Interstitial *bool               `json:"interstitial,omitempty"`
+	MaxClicks    *int64              `json:"max_clicks,omitempty"`
+	Title        *string             `json:"title,omitempty"`
+	Notes        *string             `json:"notes,omitempty"`
+	Tags         *[]string           `json:"tags,omitempty"`
+	ActiveFrom   Nullable[time.Time] `json:"active_from"`
+	ActiveUntil  Nullable[time.Time] `json:"active_until"`
+	FallbackURL  Nullable[string]    `json:"fallback_url"`
+}
+
+// Nullable tells a field set to null apart from an absent one: Set is true
+// whenever the field is present, Value is nil when it is null.
+type Nullable[T any] struct {
+	Value *T
+	Set   bool
+}
+
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+
+	n.Value = new(T)
+	return json.Unmarshal(data, n.Value)
+}
+
+type URLResponse struct {
+	ID           string     `json:"id"`
+	ShortURL     string     `json:"short_url"`
+	OriginalURL  string     `json:"original_url"`
+	IsOwner      bool       `json:"is_owner"`
+	WorkspaceID  string     `json:"workspace_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RedirectType int        `json:"redirect_type"`
+	Interstitial bool       `json:"interstitial"`
+	Protected    bool       `json:"protected"`
+	Title        string     `json:"title,omitempty"`
+	Notes        string     `json:"notes,omitempty"`
+	Tags         []string   `json:"tags"`
+	Clicks       int64      `json:"clicks"`
+	MaxClicks    int64      `json:"max_clicks,omitempty"`
+	ActiveFrom   *time.Time `json:"active_from,omitempty"`
+	ActiveUntil  *time.Time `json:"active_until,omitempty"`
+	FallbackURL  string     `json:"fallback_url,omitempty"`
+}
+
+type URLChange struct {
+	OldURL    string    `json:"old_url"`
+	NewURL    string    `json:"new_url"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type WorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type MemberRequest struct {
+	Role string `json:"role"`
+}
+
+type WorkspaceResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// Role is the role of the requesting user.
+	Role    string   `json:"role"`
+	Members []Member `json:"members"`
+}
+
+type Member struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type APIKeyRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+type APIKeyResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scope     string    `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	// Key is only returned when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type SearchResponse struct {
+	Total   int           `json:"total"`
+	Results []URLResponse `json:"results"`
+}
+
+type StatsResponse struct {
+	URLs int `json:"urls"`
+}
+
+type LinkStats struct {
+	ID          string    `json:"id"`
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Campaign    string    `json:"campaign,omitempty"`
+	Clicks      int64     `json:"clicks"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type ErrorResponse struct {